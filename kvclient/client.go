@@ -0,0 +1,404 @@
+// Package kvclient is a client of the lab2 key value servers. It sends
+// every request straight to a node that stores the key, as placed by a
+// partitioner, falls back to the other replicas of the key when a node
+// is down and returns errors instead of printing them. Listings are sent
+// to every node at once and merged, see Client.Scan.
+package kvclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SamSirsikar/DistributedSystems/partition"
+)
+
+const (
+	// DefaultRetries is the number of times a request is sent again to a
+	// node that could not be reached, before trying the next replica.
+	DefaultRetries = 2
+	// DefaultBackoff is the wait before the first retry; every further
+	// retry waits twice as long as the one before.
+	DefaultBackoff = 50 * time.Millisecond
+)
+
+// Client sends requests to a cluster of servers. It is safe for
+// concurrent use once configured.
+type Client struct {
+	// Nodes are the base urls of all the servers.
+	Nodes []string
+	// Partitioner places the keys on Nodes, as the servers do.
+	Partitioner partition.Partitioner
+	// Replicas is the number of nodes that store every key. A request is
+	// sent to the first of them that is up.
+	Replicas int
+	// HTTPClient sends the requests. The default keeps a pool of
+	// connections to every node open between requests.
+	HTTPClient *http.Client
+	// Retries and Backoff control how a node that cannot be reached is
+	// retried.
+	Retries int
+	Backoff time.Duration
+
+	sleep func(time.Duration)
+}
+
+// New returns a client of the nodes that places keys with the named
+// strategy, one of partition.Strategies, and stores each key on a single
+// node.
+func New(strategy string, nodes []string) (*Client, error) {
+	p, err := partition.New(strategy)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		p.Add(node)
+	}
+
+	return &Client{
+		Nodes:       nodes,
+		Partitioner: p,
+		Replicas:    1,
+		HTTPClient:  newHTTPClient(),
+		Retries:     DefaultRetries,
+		Backoff:     DefaultBackoff,
+		sleep:       time.Sleep,
+	}, nil
+}
+
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a client talks to the same few nodes over and over, so it keeps
+	// more than the default two idle connections to each of them
+	transport.MaxIdleConnsPerHost = 32
+
+	return &http.Client{Transport: transport, Timeout: 5 * time.Second}
+}
+
+// Get returns the value of the key. It returns an error wrapping
+// ErrNotFound if the key is not stored.
+func (c *Client) Get(key string) (string, error) {
+	node, status, body, err := c.route(key, "GET", "")
+	if err != nil {
+		return "", err
+	}
+
+	switch status {
+	case 200:
+		// the body looks like {"key": "1", "value": "A"}
+		var response struct {
+			Value string `json:"value"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return "", fmt.Errorf("%s: %w", node, err)
+		}
+		return response.Value, nil
+	case 404:
+		return "", fmt.Errorf("%w: %q", ErrNotFound, key)
+	default:
+		return "", newStatusError(node, status, body)
+	}
+}
+
+// Put stores the value at the key.
+func (c *Client) Put(key string, val string) error {
+	node, status, body, err := c.route(key, "PUT", val)
+	if err != nil {
+		return err
+	}
+	if status != 204 {
+		return newStatusError(node, status, body)
+	}
+	return nil
+}
+
+// Delete removes the key. Deleting a key that is not stored is not an
+// error.
+func (c *Client) Delete(key string) error {
+	node, status, body, err := c.route(key, "DELETE", "")
+	if err != nil {
+		return err
+	}
+	if status != 204 {
+		return newStatusError(node, status, body)
+	}
+	return nil
+}
+
+// GetAll returns every key and value stored in the cluster. It fails
+// with an UnavailableError if any node cannot be listed.
+func (c *Client) GetAll() (map[string]string, error) {
+	result, err := c.Scan(Filter{})
+	if err != nil {
+		return nil, err
+	}
+	if err := result.Err(); err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]string, len(result.Entries))
+	for _, entry := range result.Entries {
+		data[entry.Key] = entry.Value
+	}
+	return data, nil
+}
+
+// Filter selects the keys of a scan: those that start with Prefix and lie
+// in [Start, End) in byte order. Empty fields select every key.
+type Filter struct {
+	Prefix string
+	Start  string
+	End    string
+}
+
+func (f Filter) query() string {
+	params := url.Values{}
+	if f.Prefix != "" {
+		params.Set("prefix", f.Prefix)
+	}
+	if f.Start != "" {
+		params.Set("start", f.Start)
+	}
+	if f.End != "" {
+		params.Set("end", f.End)
+	}
+	return params.Encode()
+}
+
+// Entry is a key with its value.
+type Entry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ScanResult is the outcome of a scan, which can be partial: the keys
+// whose replicas all are among the Unreachable nodes are missing from
+// Entries. Errs holds the error of every unreachable node.
+type ScanResult struct {
+	// Entries are sorted by key.
+	Entries     []Entry
+	Unreachable []string
+	Errs        []error
+}
+
+// Err returns an UnavailableError of the unreachable nodes, or nil if
+// every node answered.
+func (r *ScanResult) Err() error {
+	if len(r.Unreachable) == 0 {
+		return nil
+	}
+	return &UnavailableError{Nodes: r.Unreachable, Errs: r.Errs}
+}
+
+// listHeader asks a lab2 node for the entries of its keys with their
+// versions and tombstones, one JSON object per line, instead of the
+// plain listing of its values.
+const listHeader = "X-Replica"
+
+// version orders the writes to a key, as the servers do: the larger
+// counter wins, then the larger node name.
+type version struct {
+	Counter uint64 `json:"counter"`
+	Node    string `json:"node"`
+}
+
+func (v version) newer(other version) bool {
+	if v.Counter != other.Counter {
+		return v.Counter > other.Counter
+	}
+	return v.Node > other.Node
+}
+
+// nodeEntry is what a node holds for a key, a tombstone for a deleted
+// key included.
+type nodeEntry struct {
+	Key     string  `json:"key"`
+	Value   string  `json:"value"`
+	Version version `json:"version"`
+	Deleted bool    `json:"deleted"`
+}
+
+// nodeListing is the answer of a single node to a scan.
+type nodeListing struct {
+	node    string
+	entries []nodeEntry
+	err     error
+}
+
+// Scan lists the keys the filter selects on every node at once and
+// merges the listings. A node that cannot be reached or fails does not
+// fail the scan, it is reported in the result instead; Scan only fails
+// when no node answered.
+//
+// The nodes list their entries with versions, so when the replicas of a
+// key disagree the newest entry wins and a deleted key is left out, as
+// for a server listing the whole cluster.
+func (c *Client) Scan(filter Filter) (*ScanResult, error) {
+	if len(c.Nodes) == 0 {
+		return nil, partition.ErrNoNodes
+	}
+
+	results := make(chan nodeListing, len(c.Nodes))
+	for _, node := range c.Nodes {
+		go func(node string) {
+			entries, err := c.list(node, filter)
+			results <- nodeListing{node, entries, err}
+		}(node)
+	}
+
+	newest := make(map[string]nodeEntry)
+	result := &ScanResult{Entries: make([]Entry, 0)}
+	errs := make(map[string]error)
+	for range c.Nodes {
+		listing := <-results
+		if listing.err != nil {
+			result.Unreachable = append(result.Unreachable, listing.node)
+			errs[listing.node] = listing.err
+			continue
+		}
+		for _, entry := range listing.entries {
+			if current, ok := newest[entry.Key]; !ok || entry.Version.newer(current.Version) {
+				newest[entry.Key] = entry
+			}
+		}
+	}
+
+	sort.Strings(result.Unreachable)
+	for _, node := range result.Unreachable {
+		result.Errs = append(result.Errs, errs[node])
+	}
+	if len(result.Unreachable) == len(c.Nodes) {
+		return nil, result.Err()
+	}
+
+	for key, entry := range newest {
+		if !entry.Deleted {
+			result.Entries = append(result.Entries, Entry{Key: key, Value: entry.Value})
+		}
+	}
+	sort.Slice(result.Entries, func(i, j int) bool {
+		return result.Entries[i].Key < result.Entries[j].Key
+	})
+	return result, nil
+}
+
+// list returns the entries a single node holds that the filter selects.
+func (c *Client) list(node string, filter Filter) ([]nodeEntry, error) {
+	path := "/"
+	if query := filter.query(); query != "" {
+		path += "?" + query
+	}
+
+	status, body, err := c.send(node, "GET", path, "", http.Header{listHeader: {"kvclient"}})
+	if err != nil {
+		return nil, err
+	}
+	if status != 200 {
+		return nil, newStatusError(node, status, body)
+	}
+
+	entries := make([]nodeEntry, 0)
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	for decoder.More() {
+		var entry nodeEntry
+		if err := decoder.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("%s: %w", node, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// route sends a request for the key to its replicas in order, until one
+// of them answers without a 5xx status, and returns that node with its
+// answer.
+func (c *Client) route(key string, method string, body string) (string, int, []byte, error) {
+	replicas, err := c.Partitioner.GetN(key, max(1, c.Replicas))
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	errs := make([]error, 0, len(replicas))
+	for _, node := range replicas {
+		status, response, err := c.send(node, method, "/"+url.PathEscape(key), body, nil)
+		if err == nil && status >= 500 {
+			// the node is up but cannot serve the key, another
+			// replica might
+			err = newStatusError(node, status, response)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return node, status, response, nil
+	}
+
+	return "", 0, nil, &UnavailableError{Nodes: replicas, Errs: errs}
+}
+
+// send sends a request to a single node and reads the response. A node
+// that cannot be reached is tried again Retries times, waiting Backoff
+// and then twice as long before every further attempt.
+func (c *Client) send(node string, method string, path string, body string, header http.Header) (int, []byte, error) {
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		status, response, err := c.sendOnce(node, method, path, body, header)
+		if err == nil || attempt >= c.Retries {
+			return status, response, err
+		}
+
+		if c.sleep != nil {
+			c.sleep(backoff)
+		} else {
+			time.Sleep(backoff)
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) sendOnce(node string, method string, path string, body string, header http.Header) (int, []byte, error) {
+	request, err := http.NewRequest(method, node+path, strings.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	if method == "PUT" {
+		request.Header.Set("Content-Type", "text/plain")
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer response.Body.Close()
+
+	// read the whole body, so the connection goes back to the pool
+	contents, err := io.ReadAll(response.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return response.StatusCode, contents, nil
+}
+
+// newStatusError returns the error of an unexpected response, with the
+// message of a JSON error body such as {"error": "value too large"}.
+func newStatusError(node string, status int, body []byte) *StatusError {
+	var response struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(body, &response)
+
+	return &StatusError{Node: node, StatusCode: status, Message: response.Error}
+}
@@ -0,0 +1,352 @@
+package kvclient
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SamSirsikar/DistributedSystems/partition"
+	"github.com/stretchr/testify/assert"
+)
+
+// testNode is an in-memory server with the API of a lab2 node.
+type testNode struct {
+	mu   sync.Mutex
+	data map[string]string
+	// versions counts the writes to every key, deletes included: a
+	// deleted key is left as a tombstone
+	versions map[string]uint64
+	requests int
+	server   *httptest.Server
+}
+
+func newTestNode(t *testing.T) *testNode {
+	node := &testNode{data: make(map[string]string), versions: make(map[string]uint64)}
+	node.server = httptest.NewServer(http.HandlerFunc(node.serve))
+	t.Cleanup(node.server.Close)
+	return node
+}
+
+func (n *testNode) serve(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.requests++
+
+	if r.URL.Path == "/" && r.Header.Get("X-Replica") != "" {
+		// the versioned stream of the entries a scan reads
+		query := r.URL.Query()
+		keys := make(map[string]bool)
+		for key := range n.data {
+			keys[key] = true
+		}
+		for key := range n.versions {
+			keys[key] = true
+		}
+		for key := range keys {
+			if !strings.HasPrefix(key, query.Get("prefix")) || key < query.Get("start") ||
+				(query.Get("end") != "" && key >= query.Get("end")) {
+				continue
+			}
+			val, found := n.data[key]
+			json.NewEncoder(w).Encode(nodeEntry{
+				Key:     key,
+				Value:   val,
+				Version: version{Counter: n.versions[key], Node: n.server.URL},
+				Deleted: !found,
+			})
+		}
+		return
+	}
+
+	key, _ := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/"))
+	switch r.Method {
+	case "GET":
+		val, ok := n.data[key]
+		if !ok {
+			w.WriteHeader(404)
+			json.NewEncoder(w).Encode(map[string]string{"error": "key not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"key": key, "value": val})
+	case "PUT":
+		body, _ := io.ReadAll(r.Body)
+		if len(body) > 10 {
+			w.WriteHeader(413)
+			json.NewEncoder(w).Encode(map[string]string{"error": "value too large"})
+			return
+		}
+		n.data[key] = string(body)
+		n.versions[key]++
+		w.WriteHeader(204)
+	case "DELETE":
+		delete(n.data, key)
+		n.versions[key]++
+		w.WriteHeader(204)
+	}
+}
+
+// startNodes starts count nodes and a client of them with the given
+// number of replicas, which never actually sleeps between retries but
+// records the waits.
+func startNodes(t *testing.T, count int, replicas int) (map[string]*testNode, *Client, *[]time.Duration) {
+	nodes := make(map[string]*testNode)
+	urls := make([]string, 0)
+	for i := 0; i < count; i++ {
+		node := newTestNode(t)
+		nodes[node.server.URL] = node
+		urls = append(urls, node.server.URL)
+	}
+
+	client, err := New("ring", urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Replicas = replicas
+
+	var mu sync.Mutex
+	sleeps := make([]time.Duration, 0)
+	client.sleep = func(d time.Duration) {
+		// scans retry several nodes at once
+		mu.Lock()
+		defer mu.Unlock()
+		sleeps = append(sleeps, d)
+	}
+	return nodes, client, &sleeps
+}
+
+func TestClientRoutesByPartitioner(t *testing.T) {
+	assert := assert.New(t)
+	nodes, client, _ := startNodes(t, 4, 1)
+
+	for _, key := range []string{"1", "2", "3", "user/42", "hello world"} {
+		assert.NoError(client.Put(key, "v"+key[:1]))
+
+		// only the node the partitioner picks holds the key
+		owner, err := client.Partitioner.Get(key)
+		assert.NoError(err)
+		for url, node := range nodes {
+			_, ok := node.data[key]
+			assert.Equal(url == owner, ok, key)
+		}
+
+		val, err := client.Get(key)
+		assert.NoError(err)
+		assert.Equal("v"+key[:1], val)
+	}
+
+	all, err := client.GetAll()
+	assert.NoError(err)
+	assert.Len(all, 5)
+	assert.Equal("vu", all["user/42"])
+
+	assert.NoError(client.Delete("1"))
+	assert.NoError(client.Delete("1"))
+	_, err = client.Get("1")
+	assert.ErrorIs(err, ErrNotFound)
+	assert.EqualError(err, `key not found: "1"`)
+}
+
+func TestClientReturnsStatusErrors(t *testing.T) {
+	assert := assert.New(t)
+	_, client, _ := startNodes(t, 3, 1)
+
+	err := client.Put("1", "far too long a value")
+	var statusErr *StatusError
+	if assert.ErrorAs(err, &statusErr) {
+		assert.Equal(413, statusErr.StatusCode)
+		assert.Equal("value too large", statusErr.Message)
+		owner, _ := client.Partitioner.Get("1")
+		assert.Equal(owner, statusErr.Node)
+	}
+}
+
+func TestClientFallsBackToReplicas(t *testing.T) {
+	assert := assert.New(t)
+	nodes, client, sleeps := startNodes(t, 5, 3)
+
+	replicas, _ := client.Partitioner.GetN("1", 3)
+	nodes[replicas[1]].data["1"] = "A"
+
+	// the primary is down and is retried with a growing backoff before
+	// the next replica answers
+	nodes[replicas[0]].server.Close()
+	val, err := client.Get("1")
+	assert.NoError(err)
+	assert.Equal("A", val)
+	assert.Equal([]time.Duration{DefaultBackoff, 2 * DefaultBackoff}, *sleeps)
+
+	assert.NoError(client.Put("1", "B"))
+	assert.Equal("B", nodes[replicas[1]].data["1"])
+
+	// with every replica down the request fails
+	nodes[replicas[1]].server.Close()
+	nodes[replicas[2]].server.Close()
+	_, err = client.Get("1")
+	var unavailable *UnavailableError
+	if assert.ErrorAs(err, &unavailable) {
+		assert.Equal(replicas, unavailable.Nodes)
+		assert.Len(unavailable.Errs, 3)
+	}
+	var netErr net.Error
+	assert.ErrorAs(err, &netErr)
+
+	_, err = client.GetAll()
+	assert.ErrorAs(err, &unavailable)
+}
+
+func TestClientSkipsFailingReplica(t *testing.T) {
+	assert := assert.New(t)
+	nodes, client, sleeps := startNodes(t, 3, 2)
+
+	replicas, _ := client.Partitioner.GetN("1", 2)
+	failing := nodes[replicas[0]]
+	failing.server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failing.mu.Lock()
+		failing.requests++
+		failing.mu.Unlock()
+		w.WriteHeader(503)
+	})
+
+	// a 5xx answer is not retried on the same node
+	assert.NoError(client.Put("1", "A"))
+	assert.Equal(1, failing.requests)
+	assert.Empty(*sleeps)
+	assert.Equal("A", nodes[replicas[1]].data["1"])
+}
+
+func TestClientRetriesUntilNodeIsUp(t *testing.T) {
+	assert := assert.New(t)
+
+	// reserve an address and start serving on it only after the first
+	// attempt failed
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	node := &testNode{data: map[string]string{"1": "A"}}
+	client, err := New("ring", []string{"http://" + addr})
+	assert.NoError(err)
+
+	attempts := 0
+	client.sleep = func(time.Duration) {
+		attempts++
+		if attempts == 1 {
+			listener, err := net.Listen("tcp", addr)
+			if err != nil {
+				t.Skip("address was taken:", err)
+			}
+			server := &http.Server{Handler: http.HandlerFunc(node.serve)}
+			go server.Serve(listener)
+			t.Cleanup(func() { server.Close() })
+		}
+	}
+
+	val, err := client.Get("1")
+	assert.NoError(err)
+	assert.Equal("A", val)
+	assert.Equal(1, attempts)
+}
+
+func TestClientScansEveryNode(t *testing.T) {
+	assert := assert.New(t)
+	nodes, client, _ := startNodes(t, 4, 2)
+
+	for _, key := range []string{"a", "b", "user/1", "user/2", "user/3", "z"} {
+		assert.NoError(client.Put(key, key))
+	}
+
+	result, err := client.Scan(Filter{Prefix: "user/"})
+	assert.NoError(err)
+	assert.Equal([]Entry{{"user/1", "user/1"}, {"user/2", "user/2"}, {"user/3", "user/3"}}, result.Entries)
+	assert.Empty(result.Unreachable)
+	assert.NoError(result.Err())
+
+	result, err = client.Scan(Filter{Start: "b", End: "user/2"})
+	assert.NoError(err)
+	assert.Equal([]Entry{{"b", "b"}, {"user/1", "user/1"}}, result.Entries)
+
+	// a stale replica loses to the newer entry of the key
+	replicas, _ := client.Partitioner.GetN("a", 2)
+	nodes[replicas[1]].data["a"] = "stale"
+
+	// a newer write that only reached the second replica wins
+	replicas, _ = client.Partitioner.GetN("b", 2)
+	nodes[replicas[1]].data["b"] = "newer"
+	nodes[replicas[1]].versions["b"] = 2
+
+	// a delete that only reached the first replica hides the key
+	replicas, _ = client.Partitioner.GetN("z", 2)
+	assert.NoError(client.Delete("z"))
+	nodes[replicas[1]].data["z"] = "z"
+	nodes[replicas[1]].versions["z"] = 1
+
+	all, err := client.GetAll()
+	assert.NoError(err)
+	assert.Equal(map[string]string{
+		"a":      "a",
+		"b":      "newer",
+		"user/1": "user/1",
+		"user/2": "user/2",
+		"user/3": "user/3",
+	}, all)
+}
+
+func TestClientScanReportsUnreachableNodes(t *testing.T) {
+	assert := assert.New(t)
+	nodes, client, _ := startNodes(t, 3, 1)
+
+	keys := []string{"1", "2", "3", "4", "5", "6", "7", "8"}
+	for _, key := range keys {
+		assert.NoError(client.Put(key, "v"))
+	}
+
+	down := client.Nodes[0]
+	nodes[down].server.Close()
+
+	// the keys of the other nodes are still listed
+	result, err := client.Scan(Filter{})
+	assert.NoError(err)
+	assert.Equal([]string{down}, result.Unreachable)
+	assert.Len(result.Errs, 1)
+	assert.Len(result.Entries, len(keys)-len(nodes[down].data))
+	for _, entry := range result.Entries {
+		owner, _ := client.Partitioner.Get(entry.Key)
+		assert.NotEqual(down, owner)
+	}
+	var unavailable *UnavailableError
+	if assert.ErrorAs(result.Err(), &unavailable) {
+		assert.Equal([]string{down}, unavailable.Nodes)
+	}
+
+	// GetAll wants every key
+	_, err = client.GetAll()
+	assert.ErrorAs(err, &unavailable)
+
+	// with no node up the scan fails
+	for _, node := range nodes {
+		node.server.Close()
+	}
+	_, err = client.Scan(Filter{})
+	assert.ErrorAs(err, &unavailable)
+	assert.Len(unavailable.Nodes, 3)
+}
+
+func TestNewRejectsUnknownStrategy(t *testing.T) {
+	_, err := New("modulo", []string{"http://localhost:3001"})
+	assert.Error(t, err)
+
+	client, err := New("ring", nil)
+	assert.NoError(t, err)
+	_, err = client.Get("1")
+	assert.ErrorIs(t, err, partition.ErrNoNodes)
+	_, err = client.Scan(Filter{})
+	assert.ErrorIs(t, err, partition.ErrNoNodes)
+}
@@ -0,0 +1,46 @@
+package kvclient
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotFound is returned by Get for a key that is not stored.
+var ErrNotFound = errors.New("key not found")
+
+// StatusError is a response a node gave with an unexpected status, such
+// as 400 for a malformed request or 413 for a value that is too large.
+type StatusError struct {
+	Node       string
+	StatusCode int
+	// Message is the error the node sent back, if any
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s: status %d", e.Node, e.StatusCode)
+	}
+	return fmt.Sprintf("%s: status %d: %s", e.Node, e.StatusCode, e.Message)
+}
+
+// UnavailableError is returned when none of the nodes a request was sent
+// to could serve it: they could not be reached, even after retrying, or
+// failed with a 5xx status. Errs holds the last error of every node.
+type UnavailableError struct {
+	Nodes []string
+	Errs  []error
+}
+
+func (e *UnavailableError) Error() string {
+	messages := make([]string, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("no node of %v is available: %s", e.Nodes, strings.Join(messages, "; "))
+}
+
+func (e *UnavailableError) Unwrap() []error {
+	return e.Errs
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"container/list"
+)
+
+// the four ARC lists: resident recent/frequent keys and their ghosts.
+const (
+	arcT1 = iota
+	arcT2
+	arcB1
+	arcB2
+)
+
+type arcItem[K comparable] struct {
+	key   K
+	queue int
+}
+
+// arcPolicy implements Adaptive Replacement Cache (Megiddo & Modha).
+// Resident keys live in t1 (seen once) or t2 (seen again) and evicted
+// keys are remembered in the ghost lists b1 and b2. A ghost hit moves
+// the target size p of t1 towards whichever side would have kept it.
+type arcPolicy[K comparable] struct {
+	capacity int
+	target   int
+	queues   [4]*list.List
+	hash     map[K]*list.Element
+
+	// adaptedFor remembers the incoming key p was last adapted for,
+	// since Victim may run several times before that key is admitted.
+	adaptedFor K
+	adapted    bool
+}
+
+// NewARCPolicy returns an ARC eviction policy for a cache of the given
+// capacity. The capacity bounds the ghost history and the range of p.
+func NewARCPolicy[K comparable](capacity int) Policy[K] {
+	p := &arcPolicy[K]{
+		capacity: max(1, capacity),
+		hash:     make(map[K]*list.Element),
+	}
+	for i := range p.queues {
+		p.queues[i] = list.New()
+	}
+	return p
+}
+
+func (p *arcPolicy[K]) Admit(key K) {
+	p.adapt(key)
+	p.adapted = false
+
+	if element, ok := p.hash[key]; ok {
+		// a ghost hit, the key has been seen before so it is frequent
+		p.unlink(element)
+		p.push(key, arcT2)
+		return
+	}
+
+	p.push(key, arcT1)
+
+	// trim the ghost history so the directory stays within 2c keys
+	for p.size(arcT1)+p.size(arcB1) > p.capacity && p.size(arcB1) > 0 {
+		p.unlink(p.queues[arcB1].Back())
+	}
+	for p.size(arcT1)+p.size(arcT2)+p.size(arcB1)+p.size(arcB2) > 2*p.capacity && p.size(arcB2) > 0 {
+		p.unlink(p.queues[arcB2].Back())
+	}
+}
+
+func (p *arcPolicy[K]) Access(key K) {
+	element, ok := p.hash[key]
+	if !ok {
+		return
+	}
+
+	switch element.Value.(*arcItem[K]).queue {
+	case arcT1:
+		// a second hit promotes the key to the frequent list
+		p.unlink(element)
+		p.push(key, arcT2)
+	case arcT2:
+		p.queues[arcT2].MoveToFront(element)
+	}
+}
+
+func (p *arcPolicy[K]) Remove(key K) {
+	if element, ok := p.hash[key]; ok && p.resident(element) {
+		p.unlink(element)
+	}
+}
+
+func (p *arcPolicy[K]) Victim(incoming K) (K, bool) {
+	p.adapt(incoming)
+
+	incomingInB2 := false
+	if element, ok := p.hash[incoming]; ok {
+		incomingInB2 = element.Value.(*arcItem[K]).queue == arcB2
+	}
+
+	// the REPLACE step: shrink t1 while it is above its target size
+	t1 := p.size(arcT1)
+	from, ghost := arcT2, arcB2
+	if t1 > 0 && (t1 > p.target || (incomingInB2 && t1 == p.target) || p.size(arcT2) == 0) {
+		from, ghost = arcT1, arcB1
+	}
+
+	lastElement := p.queues[from].Back()
+	if lastElement == nil {
+		var zero K
+		return zero, false
+	}
+
+	key := p.unlink(lastElement)
+	p.push(key, ghost)
+	return key, true
+}
+
+// adapt moves the target size of t1 when key is a ghost hit.
+func (p *arcPolicy[K]) adapt(key K) {
+	if p.adapted && p.adaptedFor == key {
+		return
+	}
+	p.adaptedFor, p.adapted = key, true
+
+	element, ok := p.hash[key]
+	if !ok {
+		return
+	}
+
+	b1, b2 := p.size(arcB1), p.size(arcB2)
+	switch element.Value.(*arcItem[K]).queue {
+	case arcB1:
+		p.target = min(p.capacity, p.target+max(1, b2/b1))
+	case arcB2:
+		p.target = max(0, p.target-max(1, b1/b2))
+	}
+}
+
+func (p *arcPolicy[K]) resident(element *list.Element) bool {
+	queue := element.Value.(*arcItem[K]).queue
+	return queue == arcT1 || queue == arcT2
+}
+
+func (p *arcPolicy[K]) size(queue int) int {
+	return p.queues[queue].Len()
+}
+
+func (p *arcPolicy[K]) push(key K, queue int) {
+	p.hash[key] = p.queues[queue].PushFront(&arcItem[K]{key: key, queue: queue})
+}
+
+func (p *arcPolicy[K]) unlink(element *list.Element) K {
+	item := element.Value.(*arcItem[K])
+	p.queues[item.queue].Remove(element)
+	delete(p.hash, item.key)
+	return item.key
+}
@@ -1,72 +1,293 @@
 package main
 
 import (
-	"container/list"
-	"fmt"
-	"strconv"
-	"strings"
+	"errors"
+	"time"
 )
 
 // DO NOT CHANGE THIS CACHE SIZE VALUE
 const CACHE_SIZE int = 3
 
-type LRUCache struct {
+// ErrTooLarge is returned when a single item costs more than the whole
+// capacity of the cache.
+var ErrTooLarge = errors.New("item is larger than the cache capacity")
+
+// Sizer returns the cost of storing value under key, typically its size
+// in bytes.
+type Sizer[K comparable, V any] func(key K, value V) int
+
+// Clock reports the current time. Tests inject a fake clock so that
+// expiry can be checked without sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// EvictReason describes why an entry left the cache.
+type EvictReason int
+
+const (
+	// EvictCapacity means the entry was evicted to make room.
+	EvictCapacity EvictReason = iota
+	// EvictExpired means the entry outlived its ttl.
+	EvictExpired
+	// EvictDeleted means the entry was removed with Delete.
+	EvictDeleted
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictDeleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+// Stats is a point in time snapshot of the cache counters. Evictions
+// counts entries removed for capacity or expiry, not explicit deletes.
+// Cost is the capacity used by resident entries, which equals Size
+// unless the cache was created with NewSized.
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Size      int
+	Cost      int
+}
+
+// HitRatio returns hits as a fraction of all lookups.
+func (s Stats) HitRatio() float64 {
+	lookups := s.Hits + s.Misses
+	if lookups == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(lookups)
+}
+
+// entry is the typed key/value pair stored for each resident key.
+// A zero expires means the entry never expires.
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	cost    int
+	expires time.Time
+}
+
+func (e *entry[K, V]) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// LRUCache is a fixed capacity cache that evicts the least recently
+// used entry once it is full, or whichever entry its Policy picks when
+// created with NewWithPolicy. Capacity counts entries unless the cache
+// was created with a Sizer, in which case it bounds the total cost.
+// It is not safe for concurrent use.
+type LRUCache[K comparable, V any] struct {
 	maxCapacity     int
 	currentCapacity int
-	hash            map[int]*list.Element
-	queue           *list.List
+	hash            map[K]*entry[K, V]
+	policy          Policy[K]
+	sizer           Sizer[K, V]
+	clock           Clock
+	onEvict         func(key K, value V, reason EvictReason)
+	hits            uint64
+	misses          uint64
+	evictions       uint64
 }
 
-var c = New(CACHE_SIZE)
+func New[K comparable, V any](capacity int) *LRUCache[K, V] {
+	return NewWithPolicy[K, V](capacity, NewLRUPolicy[K]())
+}
 
-func New(capacity int) *LRUCache {
+// NewWithPolicy creates a cache that asks policy which entry to evict.
+// The policy must be new and not shared with another cache.
+func NewWithPolicy[K comparable, V any](capacity int, policy Policy[K]) *LRUCache[K, V] {
+	return NewSizedWithPolicy[K, V](capacity, nil, policy)
+}
+
+// NewSized creates an LRU cache bounded by the total cost reported by
+// sizer rather than by the number of entries.
+func NewSized[K comparable, V any](maxCost int, sizer Sizer[K, V]) *LRUCache[K, V] {
+	return NewSizedWithPolicy[K, V](maxCost, sizer, NewLRUPolicy[K]())
+}
+
+// NewSizedWithPolicy is like NewSized but evicts using policy. A nil
+// sizer gives every entry a cost of one.
+func NewSizedWithPolicy[K comparable, V any](maxCost int, sizer Sizer[K, V], policy Policy[K]) *LRUCache[K, V] {
 	// initialize the cache and return
-	return &LRUCache{
-		maxCapacity:     capacity,
-		currentCapacity: capacity,
-		hash:            make(map[int]*list.Element),
-		queue:           list.New(),
+	return &LRUCache[K, V]{
+		maxCapacity:     maxCost,
+		currentCapacity: maxCost,
+		hash:            make(map[K]*entry[K, V]),
+		policy:          policy,
+		sizer:           sizer,
+		clock:           systemClock{},
 	}
 }
 
-func Set(key int, value int) {
-	// TODO: add your code here!
-	if c.currentCapacity == 0 {
-		// get the last element
-		lastElement := c.queue.Back()
+// SetClock replaces the clock used to compute and check expiry times.
+func (c *LRUCache[K, V]) SetClock(clock Clock) {
+	c.clock = clock
+}
 
-		// delete the last element from the list
-		elementValue := c.queue.Remove(lastElement)
+// OnEvict registers fn to be called with every entry that leaves the
+// cache, replacing any previously registered hook. Overwriting a key
+// with Set does not count as an eviction.
+func (c *LRUCache[K, V]) OnEvict(fn func(key K, value V, reason EvictReason)) {
+	c.onEvict = fn
+}
 
-		// fetch the actual value from the element value
-		s := strings.Split(elementValue.(string), ":")
-		tempkey := s[0]
+// Set adds or updates the value for key and records the access with
+// the policy, evicting the entries it picks until the new value fits.
+// The entry never expires. If the value alone exceeds the capacity it
+// is rejected with ErrTooLarge and any previous value for key removed.
+func (c *LRUCache[K, V]) Set(key K, value V) error {
+	return c.SetWithTTL(key, value, 0)
+}
 
-		// delete the key from hashmap
-		var intTempKey int
-		fmt.Sscan(tempkey, &intTempKey)
-		delete(c.hash, intTempKey)
+// SetWithTTL behaves like Set but the entry expires once ttl has
+// elapsed. A ttl of zero or less means the entry never expires.
+func (c *LRUCache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) error {
+	var expires time.Time
+	if ttl > 0 {
+		expires = c.clock.Now().Add(ttl)
+	}
 
-		c.currentCapacity++
+	cost := c.cost(key, value)
+	e, exists := c.hash[key]
+
+	if cost > c.maxCapacity {
+		// never serve the previous value once it has been replaced
+		if exists {
+			c.removeEntry(e, EvictCapacity)
+		}
+		return ErrTooLarge
+	}
+
+	if exists {
+		// the key already exists, update it in place
+		c.currentCapacity += e.cost - cost
+		e.value = value
+		e.cost = cost
+		e.expires = expires
+		c.policy.Access(key)
+	} else {
+		c.hash[key] = &entry[K, V]{key: key, value: value, cost: cost, expires: expires}
+		c.currentCapacity -= cost
+	}
+
+	// let the policy pick entries to evict until everything fits
+	readmit := false
+	for c.currentCapacity < 0 {
+		victim, ok := c.policy.Victim(key)
+		if !ok {
+			break
+		}
+		if victim == key {
+			// a growing update can make the policy pick the key itself,
+			// which keeps its new value and is tracked again below
+			readmit = true
+			continue
+		}
+		c.removeEntry(c.hash[victim], EvictCapacity)
+	}
+
+	if !exists || readmit {
+		c.policy.Admit(key)
+	}
+	return nil
+}
+
+// Get returns the value stored for key and records the access with the
+// policy. The boolean reports whether the key was found.
+// Expired entries are removed lazily here and reported as missing.
+func (c *LRUCache[K, V]) Get(key K) (V, bool) {
+	if e, ok := c.hash[key]; ok {
+		if !e.expired(c.clock.Now()) {
+			c.hits++
+			c.policy.Access(key)
+			return e.value, true
+		}
+		c.removeEntry(e, EvictExpired)
+	}
+
+	c.misses++
+	var zero V
+	return zero, false
+}
+
+// Delete removes key from the cache and reports whether it was present.
+func (c *LRUCache[K, V]) Delete(key K) bool {
+	if e, ok := c.hash[key]; ok {
+		c.removeEntry(e, EvictDeleted)
+		return true
+	}
+	return false
+}
+
+// RemoveExpired sweeps every expired entry out of the cache and
+// returns how many were removed.
+func (c *LRUCache[K, V]) RemoveExpired() int {
+	now := c.clock.Now()
+	removed := 0
+	for _, e := range c.hash {
+		if e.expired(now) {
+			c.removeEntry(e, EvictExpired)
+			removed++
+		}
 	}
+	return removed
+}
+
+// Len returns the number of entries currently in the cache, including
+// expired entries that have not been removed yet.
+func (c *LRUCache[K, V]) Len() int {
+	return len(c.hash)
+}
+
+// Cap returns the maximum number of entries the cache can hold, or the
+// maximum total cost for caches created with NewSized.
+func (c *LRUCache[K, V]) Cap() int {
+	return c.maxCapacity
+}
 
-	newElement := c.queue.PushFront(strconv.Itoa(key) + ":" + strconv.Itoa(value))
-	c.hash[key] = newElement
-	c.currentCapacity--
-}
-
-func Get(key int) int {
-	if valPointer, ok := c.hash[key]; ok {
-		valElement := *(*list.Element)(valPointer)
-		c.queue.MoveToFront(&valElement)
-		c.hash[key] = &valElement
-		firstElement := c.queue.Front()
-		temp := firstElement.Value
-		s := strings.Split(temp.(string), ":")
-		tempValue := s[1]
-		var value int
-		fmt.Sscan(tempValue, &value)
-		return value
+// Stats returns a snapshot of the hit, miss and eviction counters.
+func (c *LRUCache[K, V]) Stats() Stats {
+	return Stats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Size:      len(c.hash),
+		Cost:      c.maxCapacity - c.currentCapacity,
+	}
+}
+
+func (c *LRUCache[K, V]) cost(key K, value V) int {
+	if c.sizer == nil {
+		return 1
+	}
+	return max(0, c.sizer(key, value))
+}
+
+func (c *LRUCache[K, V]) removeEntry(e *entry[K, V], reason EvictReason) {
+	// a no-op for victims the policy has already forgotten
+	c.policy.Remove(e.key)
+	delete(c.hash, e.key)
+	c.currentCapacity += e.cost
+
+	if reason != EvictDeleted {
+		c.evictions++
+	}
+	if c.onEvict != nil {
+		c.onEvict(e.key, e.value, reason)
 	}
-	return -1
 }
@@ -3,23 +3,241 @@ package main
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestCache(t *testing.T) {
 	assert := assert.New(t)
 	fmt.Println("Lab 1 - Part II \n---- LRU Cache ----")
+	c := New[int, int](CACHE_SIZE)
+
 	// Populates entries into the Cache
-	Set(1, 10)
-	Set(2, 20)
-	Set(3, 30)
+	c.Set(1, 10)
+	c.Set(2, 20)
+	c.Set(3, 30)
 
-	assert.Equal(10, Get(1), "Get(1) should return 10")
-	assert.Equal(20, Get(2), "Get(2) should return 20")
-	assert.Equal(30, Get(3), "Get(3) should return 30")
+	assertGet(assert, c, 1, 10)
+	assertGet(assert, c, 2, 20)
+	assertGet(assert, c, 3, 30)
 
-	Set(4, 40)
-	assert.Equal(40, Get(4), "Get(4) should return 40")
+	c.Set(4, 40)
+	assertGet(assert, c, 4, 40)
 	// Checks Cache Invalidation
-	assert.Equal(-1, Get(1), "Get(1) should return -1 as it was the least recently used entry")
-}
\ No newline at end of file
+	_, ok := c.Get(1)
+	assert.False(ok, "Get(1) should miss as it was the least recently used entry")
+}
+
+func TestCacheIndependentInstances(t *testing.T) {
+	assert := assert.New(t)
+	a := New[string, string](CACHE_SIZE)
+	b := New[string, string](CACHE_SIZE)
+
+	a.Set("key", "a")
+	b.Set("key", "b")
+
+	assertGet(assert, a, "key", "a")
+	assertGet(assert, b, "key", "b")
+}
+
+func TestCacheUpdateDeleteLenCap(t *testing.T) {
+	assert := assert.New(t)
+	c := New[int, string](CACHE_SIZE)
+	assert.Equal(CACHE_SIZE, c.Cap())
+
+	c.Set(1, "A")
+	c.Set(2, "B")
+	c.Set(1, "AA")
+	assert.Equal(2, c.Len(), "updating a key should not add an entry")
+	assertGet(assert, c, 1, "AA")
+
+	assert.True(c.Delete(1))
+	assert.False(c.Delete(1), "deleting a missing key should report false")
+	assert.Equal(1, c.Len())
+
+	// the freed slot is reused without evicting key 2
+	c.Set(3, "C")
+	c.Set(4, "D")
+	assert.Equal(CACHE_SIZE, c.Len())
+	assertGet(assert, c, 2, "B")
+}
+
+func assertGet[K comparable, V any](assert *assert.Assertions, c *LRUCache[K, V], key K, want V) {
+	got, ok := c.Get(key)
+	assert.True(ok, "Get(%v) should hit", key)
+	assert.Equal(want, got, "Get(%v) should return %v", key, want)
+}
+
+// fakeClock is a manually advanced Clock for expiry tests.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2016, 6, 9, 0, 0, 0, 0, time.UTC)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+func TestCacheTTL(t *testing.T) {
+	assert := assert.New(t)
+	clock := newFakeClock()
+	c := New[int, string](CACHE_SIZE)
+	c.SetClock(clock)
+
+	c.SetWithTTL(1, "short", time.Second)
+	c.SetWithTTL(2, "long", time.Minute)
+	c.Set(3, "forever")
+
+	clock.Advance(999 * time.Millisecond)
+	assertGet(assert, c, 1, "short")
+
+	clock.Advance(time.Millisecond)
+	_, ok := c.Get(1)
+	assert.False(ok, "Get(1) should miss once its ttl has elapsed")
+	assert.Equal(2, c.Len(), "an expired entry should be removed lazily on Get")
+
+	clock.Advance(time.Hour)
+	assert.Equal(1, c.RemoveExpired(), "only key 2 should still be waiting to expire")
+	assertGet(assert, c, 3, "forever")
+}
+
+func TestCacheTTLResetOnUpdate(t *testing.T) {
+	assert := assert.New(t)
+	clock := newFakeClock()
+	c := New[int, string](CACHE_SIZE)
+	c.SetClock(clock)
+
+	c.SetWithTTL(1, "A", time.Second)
+	c.Set(1, "B")
+
+	clock.Advance(time.Minute)
+	assertGet(assert, c, 1, "B")
+}
+
+type eviction struct {
+	key    int
+	value  string
+	reason EvictReason
+}
+
+func TestCacheOnEvict(t *testing.T) {
+	assert := assert.New(t)
+	clock := newFakeClock()
+	c := New[int, string](CACHE_SIZE)
+	c.SetClock(clock)
+
+	var evicted []eviction
+	c.OnEvict(func(key int, value string, reason EvictReason) {
+		evicted = append(evicted, eviction{key, value, reason})
+	})
+
+	c.Set(1, "A")
+	c.Set(2, "B")
+	c.SetWithTTL(3, "C", time.Second)
+	c.Set(1, "AA")
+	c.Set(4, "D")
+	c.Delete(4)
+	clock.Advance(time.Second)
+	c.Get(3)
+
+	assert.Equal([]eviction{
+		{2, "B", EvictCapacity},
+		{4, "D", EvictDeleted},
+		{3, "C", EvictExpired},
+	}, evicted)
+}
+
+func TestCacheStats(t *testing.T) {
+	assert := assert.New(t)
+	c := New[int, int](CACHE_SIZE)
+
+	for i := 1; i <= 4; i++ {
+		c.Set(i, i*10)
+	}
+	c.Get(1)
+	c.Get(2)
+	c.Get(3)
+	c.Delete(3)
+
+	stats := c.Stats()
+	assert.Equal(Stats{Hits: 2, Misses: 1, Evictions: 1, Size: 2, Cost: 2}, stats)
+	assert.InDelta(2.0/3.0, stats.HitRatio(), 1e-9)
+	assert.Equal(0.0, Stats{}.HitRatio(), "no lookups should give a zero ratio")
+}
+
+func byteSizer(key string, value string) int {
+	return len(key) + len(value)
+}
+
+func TestSizedCacheEvictsUntilItFits(t *testing.T) {
+	assert := assert.New(t)
+	c := NewSized[string, string](10, byteSizer)
+	assert.Equal(10, c.Cap())
+
+	assert.NoError(c.Set("a", "11"))
+	assert.NoError(c.Set("b", "22"))
+	assert.NoError(c.Set("c", "33"))
+	assert.Equal(9, c.Stats().Cost)
+
+	// touch "a" so "b" and "c" are the least recently used
+	c.Get("a")
+	assert.NoError(c.Set("d", "4444"))
+
+	assert.Equal(8, c.Stats().Cost)
+	assertGet(assert, c, "a", "11")
+	assertGet(assert, c, "d", "4444")
+	_, ok := c.Get("b")
+	assert.False(ok, "Get(b) should miss as it was evicted to make room")
+	_, ok = c.Get("c")
+	assert.False(ok, "Get(c) should miss as it was evicted to make room")
+}
+
+func TestSizedCacheRejectsOversizedItems(t *testing.T) {
+	assert := assert.New(t)
+	c := NewSized[string, string](10, byteSizer)
+
+	assert.NoError(c.Set("a", "1"))
+	assert.NoError(c.Set("b", "2"))
+	assert.Equal(ErrTooLarge, c.Set("c", "this value is too long"))
+	assert.Equal(2, c.Len(), "rejecting an item should not evict anything")
+
+	assert.Equal(ErrTooLarge, c.Set("a", "this value is too long"))
+	_, ok := c.Get("a")
+	assert.False(ok, "the stale value should not be served after a rejected update")
+	assert.Equal(2, c.Stats().Cost)
+}
+
+func TestSizedCacheGrowingUpdate(t *testing.T) {
+	assert := assert.New(t)
+	c := NewSized[string, string](10, byteSizer)
+
+	c.Set("a", "1")
+	c.Set("b", "2")
+	c.Set("c", "3")
+	assert.NoError(c.Set("c", "3333333"))
+
+	assert.Equal(10, c.Stats().Cost)
+	assertGet(assert, c, "c", "3333333")
+	assertGet(assert, c, "b", "2")
+	_, ok := c.Get("a")
+	assert.False(ok, "growing c should evict the least recently used entry")
+}
+
+func TestZeroCapacityCache(t *testing.T) {
+	c := New[int, int](0)
+	assert.Equal(t, ErrTooLarge, c.Set(1, 1))
+	assert.Equal(t, 0, c.Len())
+}
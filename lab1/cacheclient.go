@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/SamSirsikar/DistributedSystems/partition"
+)
+
+// CacheClient talks to a tier of cache nodes, sending each key to the
+// node that owns it on the consistent hash ring.
+type CacheClient struct {
+	ring   *partition.Ring
+	client *http.Client
+}
+
+// NewCacheClient creates a client for the nodes at the given base urls,
+// e.g. http://localhost:4001.
+func NewCacheClient(urls []string) *CacheClient {
+	ring := partition.NewRing()
+	for _, u := range urls {
+		ring.Add(u)
+	}
+
+	return &CacheClient{
+		ring:   ring,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// NodeFor returns the base url of the node that owns key, or an empty
+// string for a client of no nodes.
+func (c *CacheClient) NodeFor(key string) string {
+	node, _ := c.ring.Get(key)
+	return node
+}
+
+// Get fetches key from its node. The boolean reports whether it was
+// cached there.
+func (c *CacheClient) Get(key string) (string, bool, error) {
+	response, err := c.do("GET", key, "", nil)
+	if err != nil {
+		return "", false, err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case 200:
+		var body struct {
+			Value string `json:"value"`
+		}
+		if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+			return "", false, err
+		}
+		return body.Value, true, nil
+	case 404:
+		return "", false, nil
+	}
+	return "", false, fmt.Errorf("GET %s: unexpected status %d", key, response.StatusCode)
+}
+
+// Set stores value under key on its node, expiring after ttl if ttl is
+// positive.
+func (c *CacheClient) Set(key string, value string, ttl time.Duration) error {
+	query := ""
+	if ttl > 0 {
+		query = "ttl=" + ttl.String()
+	}
+
+	response, err := c.do("PUT", key, query, strings.NewReader(value))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != 204 {
+		return fmt.Errorf("PUT %s: unexpected status %d", key, response.StatusCode)
+	}
+	return nil
+}
+
+// Delete removes key from its node.
+func (c *CacheClient) Delete(key string) error {
+	response, err := c.do("DELETE", key, "", nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != 204 {
+		return fmt.Errorf("DELETE %s: unexpected status %d", key, response.StatusCode)
+	}
+	return nil
+}
+
+func (c *CacheClient) do(method string, key string, query string, body io.Reader) (*http.Response, error) {
+	node, err := c.ring.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	u := fmt.Sprintf("%s/cache/%s", node, url.PathEscape(key))
+	if query != "" {
+		u += "?" + query
+	}
+
+	request, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	return c.client.Do(request)
+}
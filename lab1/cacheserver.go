@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// CacheHTTPServer runs one cache node per port, each with its own
+// LRU cache holding up to Capacity entries.
+type CacheHTTPServer struct {
+	Ports    []int
+	Capacity int
+}
+
+func NewCacheHTTPServer(ports []int, capacity int) *CacheHTTPServer {
+	return &CacheHTTPServer{
+		Ports:    ports,
+		Capacity: capacity,
+	}
+}
+
+func (h *CacheHTTPServer) Start() {
+	// create a done channel to signal server shutdown
+	done := make(chan bool)
+
+	for _, port := range h.Ports {
+		go func(port int) {
+			fmt.Println("starting cache node at port:", port)
+
+			// each node gets its own cache
+			handler := NewCacheHandler(h.Capacity)
+
+			// listen and serve http requests
+			err := http.ListenAndServe(fmt.Sprintf(":%d", port), handler)
+
+			// signal the goroutine end
+			fmt.Println("shutting down cache node at port:", port, err)
+			done <- true
+		}(port)
+	}
+
+	// wait for all the nodes to stop
+	for range h.Ports {
+		<-done
+	}
+}
+
+// maxValueSize limits the size of a value stored with PUT.
+const maxValueSize = 1 << 20
+
+var (
+	cacheKeyPattern   = regexp.MustCompile(`^/cache/([^/]+)$`)
+	cacheStatsPattern = regexp.MustCompile(`^/stats$`)
+)
+
+// NewCacheHandler returns the HTTP interface of a single cache node:
+//
+//	GET    /cache/{key}           fetch a value
+//	PUT    /cache/{key}?ttl=30s   store the request body as the value
+//	DELETE /cache/{key}           remove a value
+//	GET    /stats                 hit, miss and eviction counters
+//
+// Failed requests answer with a JSON body such as {"error": "key not found"}.
+func NewCacheHandler(capacity int) http.Handler {
+	// spread the capacity over a few shards once it is big enough
+	shards := max(1, min(8, capacity/16))
+	cache := NewSharded[string, string](shards, capacity)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case cacheKeyPattern.MatchString(r.URL.EscapedPath()):
+			matches := cacheKeyPattern.FindStringSubmatch(r.URL.EscapedPath())
+			key, err := url.PathUnescape(matches[1])
+			if err != nil {
+				writeError(w, 400, err.Error())
+				return
+			}
+
+			switch r.Method {
+			case "GET":
+				val, ok := cache.Get(key)
+				if !ok {
+					writeError(w, 404, "key not found")
+					return
+				}
+				writeJSON(w, 200, map[string]interface{}{
+					"key":   key,
+					"value": val,
+				})
+			case "PUT":
+				// reject bodies too large to be worth caching
+				r.Body = http.MaxBytesReader(w, r.Body, maxValueSize)
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					var tooLarge *http.MaxBytesError
+					if errors.As(err, &tooLarge) {
+						writeError(w, 413, "value too large")
+					} else {
+						writeError(w, 400, err.Error())
+					}
+					return
+				}
+
+				// an optional ttl such as ?ttl=30s
+				var ttl time.Duration
+				if raw := r.URL.Query().Get("ttl"); raw != "" {
+					if ttl, err = time.ParseDuration(raw); err != nil {
+						writeError(w, 400, "invalid ttl: "+raw)
+						return
+					}
+				}
+
+				if err := cache.SetWithTTL(key, string(body), ttl); err != nil {
+					writeError(w, 413, err.Error())
+					return
+				}
+				w.WriteHeader(204)
+			case "DELETE":
+				cache.Delete(key)
+				w.WriteHeader(204)
+			default:
+				// no other method allowed on this route
+				writeError(w, 405, "method not allowed")
+			}
+		case cacheStatsPattern.MatchString(r.URL.Path):
+			if r.Method != "GET" {
+				writeError(w, 405, "method not allowed")
+				return
+			}
+			stats := cache.Stats()
+			writeJSON(w, 200, map[string]interface{}{
+				"hits":      stats.Hits,
+				"misses":    stats.Misses,
+				"evictions": stats.Evictions,
+				"size":      stats.Size,
+				"capacity":  cache.Cap(),
+				"hit_ratio": stats.HitRatio(),
+			})
+		default:
+			// no such route found
+			writeError(w, 404, "no such route")
+		}
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	// convert to JSON (ignore the error for now)
+	jsonResponse, _ := json.Marshal(v)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jsonResponse)
+}
+
+// writeError writes a JSON error body such as {"error": "key not found"}.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": message,
+	})
+}
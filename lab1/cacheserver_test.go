@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SamSirsikar/DistributedSystems/partition"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startCacheTier starts n in-process cache nodes and returns their urls.
+func startCacheTier(t *testing.T, n int, capacity int) []string {
+	urls := make([]string, n)
+	for i := range urls {
+		server := httptest.NewServer(NewCacheHandler(capacity))
+		t.Cleanup(server.Close)
+		urls[i] = server.URL
+	}
+	return urls
+}
+
+func TestCacheTier(t *testing.T) {
+	assert := assert.New(t)
+	urls := startCacheTier(t, 3, 100)
+	client := NewCacheClient(urls)
+
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("key %d/%d", i, i)
+		assert.NoError(client.Set(key, fmt.Sprint(i), 0))
+	}
+
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("key %d/%d", i, i)
+		val, ok, err := client.Get(key)
+		assert.NoError(err)
+		assert.True(ok)
+		assert.Equal(fmt.Sprint(i), val)
+
+		// the value lives only on the node that owns the key
+		for _, u := range urls {
+			_, ok, err := NewCacheClient([]string{u}).Get(key)
+			assert.NoError(err)
+			assert.Equal(u == client.NodeFor(key), ok, "%s should only be cached on its owner", key)
+		}
+	}
+
+	assert.NoError(client.Delete("key 0/0"))
+	_, ok, err := client.Get("key 0/0")
+	assert.NoError(err)
+	assert.False(ok, "a deleted key should miss")
+}
+
+func TestCacheNodeCapacityAndStats(t *testing.T) {
+	assert := assert.New(t)
+	urls := startCacheTier(t, 1, 2)
+	client := NewCacheClient(urls)
+
+	client.Set("1", "A", 0)
+	client.Set("2", "B", 0)
+	client.Set("3", "C", 0)
+
+	_, ok, _ := client.Get("1")
+	assert.False(ok, "the node should evict its least recently used entry")
+	_, ok, _ = client.Get("3")
+	assert.True(ok)
+
+	response, err := http.Get(urls[0] + "/stats")
+	assert.NoError(err)
+	defer response.Body.Close()
+	assert.Equal("application/json", response.Header.Get("Content-Type"))
+
+	var stats map[string]float64
+	assert.NoError(json.NewDecoder(response.Body).Decode(&stats))
+	assert.Equal(1.0, stats["hits"])
+	assert.Equal(1.0, stats["misses"])
+	assert.Equal(1.0, stats["evictions"])
+	assert.Equal(2.0, stats["capacity"])
+}
+
+func TestCacheNodeRequests(t *testing.T) {
+	assert := assert.New(t)
+	url := startCacheTier(t, 1, 10)[0]
+
+	request, _ := http.NewRequest("PUT", url+"/cache/k?ttl=bogus", strings.NewReader("v"))
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(err)
+	assert.Equal(400, response.StatusCode, "an invalid ttl should be rejected")
+	assert.Equal(map[string]string{"error": "invalid ttl: bogus"}, decodeError(t, response))
+
+	request, _ = http.NewRequest("PUT", url+"/cache/k", strings.NewReader(strings.Repeat("v", maxValueSize+1)))
+	response, err = http.DefaultClient.Do(request)
+	assert.NoError(err)
+	assert.Equal(413, response.StatusCode, "an oversized value should be rejected")
+	assert.Equal(map[string]string{"error": "value too large"}, decodeError(t, response))
+
+	response, err = http.Post(url+"/cache/k", "text/plain", strings.NewReader("v"))
+	assert.NoError(err)
+	assert.Equal(405, response.StatusCode)
+	assert.Equal(map[string]string{"error": "method not allowed"}, decodeError(t, response))
+
+	response, err = http.Get(url + "/nothing/here")
+	assert.NoError(err)
+	assert.Equal(404, response.StatusCode)
+	assert.Equal("application/json", response.Header.Get("Content-Type"))
+	response.Body.Close()
+
+	response, err = http.Get(url + "/cache/missing")
+	assert.NoError(err)
+	assert.Equal(404, response.StatusCode)
+	assert.Equal(map[string]string{"error": "key not found"}, decodeError(t, response))
+
+	client := NewCacheClient([]string{url})
+	assert.NoError(client.Set("k", "v", time.Hour))
+	val, ok, err := client.Get("k")
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal("v", val)
+}
+
+// decodeError reads the JSON error body of a failed request.
+func decodeError(t *testing.T, response *http.Response) map[string]string {
+	defer response.Body.Close()
+	var body map[string]string
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&body))
+	return body
+}
+
+func TestCacheClientWithoutNodes(t *testing.T) {
+	client := NewCacheClient(nil)
+	assert.Equal(t, "", client.NodeFor("k"))
+
+	_, _, err := client.Get("k")
+	assert.ErrorIs(t, err, partition.ErrNoNodes)
+	assert.ErrorIs(t, client.Set("k", "v", 0), partition.ErrNoNodes)
+}
@@ -23,4 +23,52 @@ func TestCountIslands(t *testing.T) {
 
 	fmt.Println(rows)
 	assert.Equal(3, CountIslands(rows), "Number of islands should be 3")
-}
\ No newline at end of file
+}
+
+func TestCountIslandsDistinguishesCoordinates(t *testing.T) {
+	// (1,11) and (11,1) used to share the visited key "111"
+	rows := make([][]int, 12)
+	for i := range rows {
+		rows[i] = make([]int, 12)
+	}
+	rows[1][11] = 1
+	rows[11][1] = 1
+
+	assert.Equal(t, 2, CountIslands(rows), "Number of islands should be 2")
+}
+
+func TestCountIslandsEdgeCases(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(0, CountIslands([][]int{}), "an empty grid has no islands")
+	assert.Equal(0, CountIslands([][]int{{0, 0}, {0, 0}}))
+	assert.Equal(1, CountIslands([][]int{{1}}))
+	assert.Equal(2, CountIslands([][]int{{1, 0, 1}, {0}}), "ragged rows should be treated as water")
+}
+
+func TestCountIslandsConnectivity(t *testing.T) {
+	assert := assert.New(t)
+	rows := [][]int{
+		{1, 0, 1},
+		{0, 1, 0},
+		{1, 0, 1},
+	}
+
+	assert.Equal(5, CountIslandsWith(rows, FourConnected))
+	assert.Equal(1, CountIslandsWith(rows, EightConnected))
+}
+
+func TestCountIslandsLargeGrid(t *testing.T) {
+	// a single snaking island this big overflowed the recursive version
+	const size = 2000
+	rows := make([][]int, size)
+	for i := range rows {
+		rows[i] = make([]int, size)
+		for j := range rows[i] {
+			if i%2 == 0 || (i%4 == 1 && j == size-1) || (i%4 == 3 && j == 0) {
+				rows[i][j] = 1
+			}
+		}
+	}
+
+	assert.Equal(t, 1, CountIslands(rows), "Number of islands should be 1")
+}
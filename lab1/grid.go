@@ -0,0 +1,145 @@
+package main
+
+// Cell is a grid coordinate.
+type Cell struct {
+	Row int
+	Col int
+}
+
+// Bounds is the inclusive bounding box of a component.
+type Bounds struct {
+	MinRow int
+	MinCol int
+	MaxRow int
+	MaxCol int
+}
+
+// Connectivity selects which neighbouring cells are joined together.
+type Connectivity int
+
+const (
+	// FourConnected joins cells sharing an edge.
+	FourConnected Connectivity = 4
+	// EightConnected also joins cells touching at a corner.
+	EightConnected Connectivity = 8
+)
+
+var neighbourOffsets = map[Connectivity][]Cell{
+	FourConnected: {{-1, 0}, {0, -1}, {0, 1}, {1, 0}},
+	EightConnected: {
+		{-1, -1}, {-1, 0}, {-1, 1},
+		{0, -1}, {0, 1},
+		{1, -1}, {1, 0}, {1, 1},
+	},
+}
+
+// Component is a connected region of land cells. Labels start at 1 and
+// follow the row-major order of each component's first cell.
+type Component struct {
+	Label  int
+	Cells  []Cell
+	Size   int
+	Bounds Bounds
+}
+
+// isLand reports whether (row, col) is inside the grid and non-zero.
+// Rows may have different lengths; missing cells count as water.
+func isLand(grid [][]int, row int, col int) bool {
+	return row >= 0 && row < len(grid) && col >= 0 && col < len(grid[row]) && grid[row][col] != 0
+}
+
+// gridWidth returns the length of the longest row.
+func gridWidth(grid [][]int) int {
+	width := 0
+	for _, row := range grid {
+		width = max(width, len(row))
+	}
+	return width
+}
+
+// floodFill labels every component with an iterative breadth first
+// search, calling visit (if not nil) for each land cell, and returns
+// the number of components. It never recurses, so very large regions
+// cannot overflow the stack.
+func floodFill(grid [][]int, conn Connectivity, visit func(label int, cell Cell)) int {
+	rows, cols := len(grid), gridWidth(grid)
+	offsets, ok := neighbourOffsets[conn]
+	if !ok {
+		offsets = neighbourOffsets[FourConnected]
+	}
+
+	// cells are indexed as row*cols+col so the bookkeeping stays flat
+	visited := make([]bool, rows*cols)
+	queue := make([]int, 0, 64)
+	label := 0
+
+	for i := 0; i < rows; i++ {
+		for j := 0; j < len(grid[i]); j++ {
+			if grid[i][j] == 0 || visited[i*cols+j] {
+				continue
+			}
+
+			label++
+			visited[i*cols+j] = true
+			queue = append(queue[:0], i*cols+j)
+
+			for head := 0; head < len(queue); head++ {
+				index := queue[head]
+				cell := Cell{index / cols, index % cols}
+				if visit != nil {
+					visit(label, cell)
+				}
+
+				for _, offset := range offsets {
+					row, col := cell.Row+offset.Row, cell.Col+offset.Col
+					if isLand(grid, row, col) && !visited[row*cols+col] {
+						visited[row*cols+col] = true
+						queue = append(queue, row*cols+col)
+					}
+				}
+			}
+		}
+	}
+
+	return label
+}
+
+// Components returns every connected land region of the grid together
+// with its cells, size and bounding box.
+func Components(grid [][]int, conn Connectivity) []Component {
+	components := make([]Component, 0)
+
+	floodFill(grid, conn, func(label int, cell Cell) {
+		if label > len(components) {
+			components = append(components, Component{
+				Label:  label,
+				Bounds: Bounds{cell.Row, cell.Col, cell.Row, cell.Col},
+			})
+		}
+
+		component := &components[label-1]
+		component.Cells = append(component.Cells, cell)
+		component.Size++
+		component.Bounds.MinRow = min(component.Bounds.MinRow, cell.Row)
+		component.Bounds.MinCol = min(component.Bounds.MinCol, cell.Col)
+		component.Bounds.MaxRow = max(component.Bounds.MaxRow, cell.Row)
+		component.Bounds.MaxCol = max(component.Bounds.MaxCol, cell.Col)
+	})
+
+	return components
+}
+
+// Label returns a grid of the same shape where water is 0 and every land
+// cell holds the label of its component, along with the component count.
+func Label(grid [][]int, conn Connectivity) ([][]int, int) {
+	labels := make([][]int, len(grid))
+	for i := range grid {
+		labels[i] = make([]int, len(grid[i]))
+	}
+
+	count := floodFill(grid, conn, func(label int, cell Cell) {
+		labels[cell.Row][cell.Col] = label
+	})
+
+	return labels, count
+}
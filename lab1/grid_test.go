@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestComponents(t *testing.T) {
+	assert := assert.New(t)
+	rows := [][]int{
+		{1, 1, 0, 0, 1},
+		{0, 1, 0, 0, 1},
+		{0, 0, 0, 1, 0},
+	}
+
+	components := Components(rows, FourConnected)
+	assert.Equal([]Component{
+		{
+			Label:  1,
+			Cells:  []Cell{{0, 0}, {0, 1}, {1, 1}},
+			Size:   3,
+			Bounds: Bounds{MinRow: 0, MinCol: 0, MaxRow: 1, MaxCol: 1},
+		},
+		{
+			Label:  2,
+			Cells:  []Cell{{0, 4}, {1, 4}},
+			Size:   2,
+			Bounds: Bounds{MinRow: 0, MinCol: 4, MaxRow: 1, MaxCol: 4},
+		},
+		{
+			Label:  3,
+			Cells:  []Cell{{2, 3}},
+			Size:   1,
+			Bounds: Bounds{MinRow: 2, MinCol: 3, MaxRow: 2, MaxCol: 3},
+		},
+	}, components)
+
+	components = Components(rows, EightConnected)
+	assert.Len(components, 2)
+	assert.Equal(3, components[1].Size)
+	assert.Equal(Bounds{MinRow: 0, MinCol: 3, MaxRow: 2, MaxCol: 4}, components[1].Bounds)
+}
+
+func TestLabel(t *testing.T) {
+	assert := assert.New(t)
+	rows := [][]int{
+		{1, 0, 2},
+		{1, 0, 2},
+		{0, 3, 0},
+	}
+
+	labels, count := Label(rows, FourConnected)
+	assert.Equal(3, count)
+	assert.Equal([][]int{
+		{1, 0, 2},
+		{1, 0, 2},
+		{0, 3, 0},
+	}, labels)
+
+	labels, count = Label(rows, EightConnected)
+	assert.Equal(1, count)
+	assert.Equal([][]int{
+		{1, 0, 1},
+		{1, 0, 1},
+		{0, 1, 0},
+	}, labels)
+}
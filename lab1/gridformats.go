@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// RowReader yields the rows of a grid from top to bottom, so grids that
+// do not fit in memory can be processed one row at a time. ReadRow
+// returns io.EOF after the last row. The returned slice is only valid
+// until the next call.
+type RowReader interface {
+	ReadRow() ([]int, error)
+}
+
+// ReadGrid reads every remaining row into a dense in-memory grid.
+func ReadGrid(rows RowReader) ([][]int, error) {
+	grid := make([][]int, 0)
+	for {
+		row, err := rows.ReadRow()
+		if err == io.EOF {
+			return grid, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		grid = append(grid, append([]int(nil), row...))
+	}
+}
+
+// denseRows reads the rows of an in-memory grid.
+type denseRows struct {
+	grid [][]int
+	next int
+}
+
+// NewDenseRows returns a RowReader over an in-memory grid.
+func NewDenseRows(grid [][]int) RowReader {
+	return &denseRows{grid: grid}
+}
+
+func (d *denseRows) ReadRow() ([]int, error) {
+	if d.next >= len(d.grid) {
+		return nil, io.EOF
+	}
+	d.next++
+	return d.grid[d.next-1], nil
+}
+
+// sparseRows expands a list of land cells into rows.
+type sparseRows struct {
+	rows  int
+	cells []Cell
+	row   []int
+	next  int
+}
+
+// NewSparseRows returns a RowReader for a rows x cols grid that is water
+// everywhere except at the given land cells. Cells outside the grid are
+// ignored.
+func NewSparseRows(rows int, cols int, cells []Cell) RowReader {
+	sorted := make([]Cell, 0, len(cells))
+	for _, cell := range cells {
+		if cell.Row >= 0 && cell.Row < rows && cell.Col >= 0 && cell.Col < cols {
+			sorted = append(sorted, cell)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Row < sorted[j].Row
+	})
+
+	return &sparseRows{rows: rows, cells: sorted, row: make([]int, cols)}
+}
+
+func (s *sparseRows) ReadRow() ([]int, error) {
+	if s.next >= s.rows {
+		return nil, io.EOF
+	}
+
+	clear(s.row)
+	for len(s.cells) > 0 && s.cells[0].Row == s.next {
+		s.row[s.cells[0].Col] = 1
+		s.cells = s.cells[1:]
+	}
+	s.next++
+	return s.row, nil
+}
+
+// CountIslandsSparse counts the islands formed by a list of land cells
+// in time proportional to the number of cells, however large the grid
+// they are drawn from.
+func CountIslandsSparse(cells []Cell, conn Connectivity) int {
+	offsets, ok := neighbourOffsets[conn]
+	if !ok {
+		offsets = neighbourOffsets[FourConnected]
+	}
+
+	index := make(map[Cell]int, len(cells))
+	sets := newUnionFind(0)
+	count := 0
+	for _, cell := range cells {
+		if _, ok := index[cell]; ok {
+			continue
+		}
+		index[cell] = sets.add()
+		count++
+
+		for _, offset := range offsets {
+			neighbour, ok := index[Cell{cell.Row + offset.Row, cell.Col + offset.Col}]
+			if ok && sets.union(index[cell], neighbour) {
+				count--
+			}
+		}
+	}
+	return count
+}
+
+// Run is a sequence of Length cells holding the same Value.
+type Run struct {
+	Value  int
+	Length int
+}
+
+// rleRows expands run-length encoded rows.
+type rleRows struct {
+	rows [][]Run
+	row  []int
+	next int
+}
+
+// NewRLERows returns a RowReader over run-length encoded rows.
+func NewRLERows(rows [][]Run) RowReader {
+	return &rleRows{rows: rows}
+}
+
+func (r *rleRows) ReadRow() ([]int, error) {
+	if r.next >= len(r.rows) {
+		return nil, io.EOF
+	}
+
+	r.row = r.row[:0]
+	for _, run := range r.rows[r.next] {
+		for i := 0; i < run.Length; i++ {
+			r.row = append(r.row, run.Value)
+		}
+	}
+	r.next++
+	return r.row, nil
+}
+
+// textRows parses a grid stored as text, one row per line.
+type textRows struct {
+	reader *bufio.Reader
+	row    []int
+	line   int
+}
+
+// NewTextRows returns a RowReader for a text grid with one row per line,
+// written either as digits ("11000") or as whitespace separated
+// integers ("1 1 0 0 0"). Blank lines are skipped.
+func NewTextRows(r io.Reader) RowReader {
+	return &textRows{reader: bufio.NewReader(r)}
+}
+
+func (t *textRows) ReadRow() ([]int, error) {
+	for {
+		line, err := t.reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return nil, err
+		}
+		t.line++
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		t.row = t.row[:0]
+		if strings.IndexFunc(line, unicode.IsSpace) < 0 {
+			// a run of single digit cells
+			for _, r := range line {
+				if r < '0' || r > '9' {
+					return nil, fmt.Errorf("line %d: invalid cell %q", t.line, r)
+				}
+				t.row = append(t.row, int(r-'0'))
+			}
+			return t.row, nil
+		}
+
+		for _, field := range strings.Fields(line) {
+			val, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid cell %q", t.line, field)
+			}
+			t.row = append(t.row, val)
+		}
+		return t.row, nil
+	}
+}
+
+// pgmRows reads the raster of a PGM image row by row.
+type pgmRows struct {
+	reader    *bufio.Reader
+	binary    bool
+	width     int
+	height    int
+	maxValue  int
+	threshold int
+	row       []int
+	next      int
+}
+
+// NewPGMRows returns a RowReader for a plain (P2) or raw (P5) PGM image.
+// Pixels whose value is at least threshold become land (1), the rest
+// water (0).
+func NewPGMRows(r io.Reader, threshold int) (RowReader, error) {
+	p := &pgmRows{reader: bufio.NewReader(r), threshold: threshold}
+
+	magic, err := p.token()
+	if err != nil {
+		return nil, err
+	}
+	switch magic {
+	case "P2":
+	case "P5":
+		p.binary = true
+	default:
+		return nil, fmt.Errorf("unsupported PGM format %q", magic)
+	}
+
+	header := []*int{&p.width, &p.height, &p.maxValue}
+	for _, field := range header {
+		if *field, err = p.number(); err != nil {
+			return nil, err
+		}
+	}
+	if p.width <= 0 || p.height < 0 || p.maxValue <= 0 || p.maxValue > 65535 {
+		return nil, errors.New("invalid PGM header")
+	}
+
+	p.row = make([]int, p.width)
+	return p, nil
+}
+
+func (p *pgmRows) ReadRow() ([]int, error) {
+	if p.next >= p.height {
+		return nil, io.EOF
+	}
+
+	for col := range p.row {
+		var val int
+		var err error
+		if p.binary {
+			val, err = p.pixel()
+		} else {
+			val, err = p.number()
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", p.next, err)
+		}
+
+		p.row[col] = 0
+		if val >= p.threshold {
+			p.row[col] = 1
+		}
+	}
+	p.next++
+	return p.row, nil
+}
+
+// pixel reads a raw sample, which is two bytes wide above 255.
+func (p *pgmRows) pixel() (int, error) {
+	high, err := p.reader.ReadByte()
+	if err != nil || p.maxValue < 256 {
+		return int(high), err
+	}
+	low, err := p.reader.ReadByte()
+	return int(high)<<8 | int(low), err
+}
+
+func (p *pgmRows) number() (int, error) {
+	token, err := p.token()
+	if err != nil {
+		return 0, err
+	}
+	val, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, fmt.Errorf("invalid PGM number %q", token)
+	}
+	return val, nil
+}
+
+// token reads the next whitespace separated header or plain raster
+// token, skipping '#' comments. The single whitespace byte that ends the
+// header of a raw image is consumed along with the token.
+func (p *pgmRows) token() (string, error) {
+	var token []byte
+	for {
+		b, err := p.reader.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(token) > 0 {
+				return string(token), nil
+			}
+			return "", err
+		}
+
+		switch {
+		case b == '#' && len(token) == 0:
+			if _, err := p.reader.ReadString('\n'); err != nil {
+				return "", err
+			}
+		case b == ' ' || b == '\t' || b == '\n' || b == '\r':
+			if len(token) > 0 {
+				return string(token), nil
+			}
+		default:
+			token = append(token, b)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+var formatGrid = [][]int{
+	{1, 1, 0, 0, 0},
+	{1, 1, 0, 0, 0},
+	{0, 0, 1, 0, 0},
+	{0, 0, 0, 1, 1},
+}
+
+func TestSparseRows(t *testing.T) {
+	assert := assert.New(t)
+	cells := []Cell{{3, 4}, {0, 0}, {1, 1}, {0, 1}, {2, 2}, {1, 0}, {3, 3}, {9, 9}}
+
+	grid, err := ReadGrid(NewSparseRows(4, 5, cells))
+	assert.NoError(err)
+	assert.Equal(formatGrid, grid)
+}
+
+func TestCountIslandsSparse(t *testing.T) {
+	assert := assert.New(t)
+	cells := []Cell{{0, 0}, {0, 1}, {1, 0}, {1, 1}, {2, 2}, {3, 3}, {3, 4}, {3, 4}}
+	assert.Equal(3, CountIslandsSparse(cells, FourConnected))
+	assert.Equal(1, CountIslandsSparse(cells, EightConnected))
+
+	// a huge, nearly empty grid
+	far := []Cell{{0, 0}, {1_000_000_000, 1_000_000_000}, {1_000_000_000, 999_999_999}}
+	assert.Equal(2, CountIslandsSparse(far, FourConnected))
+
+	r := rand.New(rand.NewSource(5))
+	for trial := 0; trial < 50; trial++ {
+		grid := randomGrid(r, 1+r.Intn(20), 1+r.Intn(20), r.Float64())
+		cells := make([]Cell, 0)
+		for i := range grid {
+			for j := range grid[i] {
+				if grid[i][j] != 0 {
+					cells = append(cells, Cell{i, j})
+				}
+			}
+		}
+		r.Shuffle(len(cells), func(i, j int) { cells[i], cells[j] = cells[j], cells[i] })
+
+		for _, conn := range []Connectivity{FourConnected, EightConnected} {
+			assert.Equal(CountIslandsWith(grid, conn), CountIslandsSparse(cells, conn))
+		}
+	}
+}
+
+func TestRLERows(t *testing.T) {
+	assert := assert.New(t)
+	grid, err := ReadGrid(NewRLERows([][]Run{
+		{{1, 2}, {0, 3}},
+		{{1, 2}, {0, 3}},
+		{{0, 2}, {1, 1}, {0, 2}},
+		{{0, 3}, {1, 2}},
+	}))
+	assert.NoError(err)
+	assert.Equal(formatGrid, grid)
+}
+
+func TestTextRows(t *testing.T) {
+	assert := assert.New(t)
+
+	grid, err := ReadGrid(NewTextRows(strings.NewReader("11000\n11000\n\n00100\n00011")))
+	assert.NoError(err)
+	assert.Equal(formatGrid, grid)
+
+	grid, err = ReadGrid(NewTextRows(strings.NewReader("1 1 0 0 0\r\n1 1 0 0 0\r\n0 0 1 0 0\r\n0 0 0 1 1\r\n")))
+	assert.NoError(err)
+	assert.Equal(formatGrid, grid)
+
+	_, err = ReadGrid(NewTextRows(strings.NewReader("110\n1x0\n")))
+	assert.EqualError(err, `line 2: invalid cell 'x'`)
+}
+
+func TestPGMRows(t *testing.T) {
+	assert := assert.New(t)
+
+	plain := "P2\n# a comment\n5 4\n255\n" +
+		"200 255 0 0 0\n" +
+		"255 128 0 0 0\n" +
+		"0 0 90 0 0\n" +
+		"0 0 0 255 255\n"
+	rows, err := NewPGMRows(strings.NewReader(plain), 100)
+	assert.NoError(err)
+	grid, err := ReadGrid(rows)
+	assert.NoError(err)
+	assert.Equal([][]int{
+		{1, 1, 0, 0, 0},
+		{1, 1, 0, 0, 0},
+		{0, 0, 0, 0, 0},
+		{0, 0, 0, 1, 1},
+	}, grid)
+
+	raw := bytes.NewBufferString("P5 5 4 255\n")
+	for _, row := range formatGrid {
+		for _, cell := range row {
+			raw.WriteByte(byte(cell * 255))
+		}
+	}
+	rows, err = NewPGMRows(raw, 1)
+	assert.NoError(err)
+	count, err := CountIslandsStream(rows, FourConnected)
+	assert.NoError(err)
+	assert.Equal(3, count)
+
+	// 16-bit samples are stored big-endian
+	rows, err = NewPGMRows(bytes.NewReader([]byte{'P', '5', ' ', '2', ' ', '1', ' ', '6', '5', '5', '3', '5', '\n', 0x01, 0x00, 0x00, 0xff}), 256)
+	assert.NoError(err)
+	grid, err = ReadGrid(rows)
+	assert.NoError(err)
+	assert.Equal([][]int{{1, 0}}, grid)
+
+	_, err = NewPGMRows(strings.NewReader("P6 1 1 255\n"), 1)
+	assert.Error(err, "colour images are not supported")
+
+	rows, err = NewPGMRows(strings.NewReader("P2 2 2 255\n1 1\n1\n"), 1)
+	assert.NoError(err)
+	_, err = ReadGrid(rows)
+	assert.Error(err, "a truncated raster should be reported")
+}
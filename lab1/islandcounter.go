@@ -0,0 +1,135 @@
+package main
+
+// IslandCounter tracks the number of islands in a rows x cols grid of
+// water as land cells are added or removed one at a time.
+type IslandCounter struct {
+	rows  int
+	cols  int
+	conn  Connectivity
+	land  []bool
+	count int
+
+	// every time a cell becomes land it gets a fresh union-find element,
+	// so a removed cell never drags its old set membership back in
+	sets  *unionFind
+	nodes []int
+}
+
+// NewIslandCounter creates a counter for an all-water grid.
+func NewIslandCounter(rows int, cols int, conn Connectivity) *IslandCounter {
+	if _, ok := neighbourOffsets[conn]; !ok {
+		conn = FourConnected
+	}
+
+	return &IslandCounter{
+		rows:  rows,
+		cols:  cols,
+		conn:  conn,
+		land:  make([]bool, rows*cols),
+		sets:  newUnionFind(0),
+		nodes: make([]int, rows*cols),
+	}
+}
+
+// Count returns the current number of islands.
+func (c *IslandCounter) Count() int {
+	return c.count
+}
+
+// IsLand reports whether (row, col) is currently land.
+func (c *IslandCounter) IsLand(row int, col int) bool {
+	return c.inside(row, col) && c.land[row*c.cols+col]
+}
+
+// AddLand turns (row, col) into land and returns the island count.
+// Adding a cell outside the grid or one that is already land is a no-op.
+// Each call takes near constant amortized time.
+func (c *IslandCounter) AddLand(row int, col int) int {
+	if !c.inside(row, col) || c.land[row*c.cols+col] {
+		return c.count
+	}
+
+	index := row*c.cols + col
+	c.land[index] = true
+
+	// leaf removals leave dead elements behind, compact them eventually
+	if len(c.sets.parent) >= 2*len(c.land) {
+		c.rebuild()
+		return c.count
+	}
+
+	c.nodes[index] = c.sets.add()
+	c.count++
+
+	// merge with every neighbouring island, each merge removes one
+	c.join(row, col, false)
+
+	return c.count
+}
+
+// RemoveLand turns (row, col) back into water and returns the island
+// count. Removing a cell can split an island, which union-find cannot
+// undo, so unless the cell had at most one land neighbour the sets are
+// rebuilt in O(rows*cols).
+func (c *IslandCounter) RemoveLand(row int, col int) int {
+	if !c.IsLand(row, col) {
+		return c.count
+	}
+
+	neighbours := 0
+	for _, offset := range neighbourOffsets[c.conn] {
+		if c.IsLand(row+offset.Row, col+offset.Col) {
+			neighbours++
+		}
+	}
+
+	c.land[row*c.cols+col] = false
+
+	switch neighbours {
+	case 0:
+		// the cell was an island on its own
+		c.count--
+	case 1:
+		// removing a leaf cannot disconnect the rest of its island
+	default:
+		c.rebuild()
+	}
+
+	return c.count
+}
+
+func (c *IslandCounter) rebuild() {
+	c.sets = newUnionFind(0)
+	c.count = 0
+
+	for row := 0; row < c.rows; row++ {
+		for col := 0; col < c.cols; col++ {
+			if c.land[row*c.cols+col] {
+				c.nodes[row*c.cols+col] = c.sets.add()
+				c.count++
+				c.join(row, col, true)
+			}
+		}
+	}
+}
+
+// join unions (row, col) with its land neighbours, decrementing the
+// count for every separate island it merges. When scanning in row-major
+// order only the neighbours that were already visited are considered.
+func (c *IslandCounter) join(row int, col int, visitedOnly bool) {
+	node := c.nodes[row*c.cols+col]
+	for _, offset := range neighbourOffsets[c.conn] {
+		if visitedOnly && (offset.Row > 0 || (offset.Row == 0 && offset.Col > 0)) {
+			continue
+		}
+		if c.IsLand(row+offset.Row, col+offset.Col) {
+			if c.sets.union(node, c.nodes[(row+offset.Row)*c.cols+col+offset.Col]) {
+				c.count--
+			}
+		}
+	}
+}
+
+func (c *IslandCounter) inside(row int, col int) bool {
+	return row >= 0 && row < c.rows && col >= 0 && col < c.cols
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestIslandCounter(t *testing.T) {
+	assert := assert.New(t)
+	c := NewIslandCounter(3, 3, FourConnected)
+
+	assert.Equal(1, c.AddLand(0, 0))
+	assert.Equal(1, c.AddLand(0, 1))
+	assert.Equal(2, c.AddLand(1, 2))
+	assert.Equal(3, c.AddLand(2, 1))
+	assert.Equal(1, c.AddLand(1, 1), "the middle cell should join all three islands")
+	assert.Equal(1, c.AddLand(1, 1), "adding existing land is a no-op")
+	assert.Equal(1, c.AddLand(5, 5), "adding outside the grid is a no-op")
+
+	assert.Equal(3, c.RemoveLand(1, 1), "removing the middle cell should split the island")
+	assert.Equal(3, c.RemoveLand(0, 0), "removing a leaf should not change the count")
+	assert.Equal(3, c.AddLand(0, 0), "re-adding a removed leaf should rejoin its island")
+	assert.Equal(2, c.RemoveLand(2, 1))
+	assert.Equal(2, c.Count())
+}
+
+// TestIslandCounterMatchesCountIslands replays random additions and
+// removals and checks the running count against a full recount.
+func TestIslandCounterMatchesCountIslands(t *testing.T) {
+	for _, conn := range []Connectivity{FourConnected, EightConnected} {
+		r := rand.New(rand.NewSource(int64(conn)))
+		for trial := 0; trial < 20; trial++ {
+			rows, cols := 1+r.Intn(12), 1+r.Intn(12)
+			grid := make([][]int, rows)
+			for i := range grid {
+				grid[i] = make([]int, cols)
+			}
+			c := NewIslandCounter(rows, cols, conn)
+
+			for step := 0; step < 300; step++ {
+				row, col := r.Intn(rows), r.Intn(cols)
+				var got int
+				// bias towards adding so the grid fills up over time
+				if r.Intn(3) == 0 {
+					grid[row][col] = 0
+					got = c.RemoveLand(row, col)
+				} else {
+					grid[row][col] = 1
+					got = c.AddLand(row, col)
+				}
+
+				if want := CountIslandsWith(grid, conn); got != want {
+					t.Fatalf("%d-connected %dx%d grid, step %d: got %d islands, want %d\n%v",
+						conn, rows, cols, step, got, want, grid)
+				}
+			}
+			assert.Equal(t, CountIslandsWith(grid, conn), c.Count())
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// StartJanitor starts a background goroutine that sweeps expired
+// entries out of the cache every interval. Calling the returned
+// function stops the janitor and waits for a sweep in progress to
+// finish; it is safe to call more than once.
+func (s *ShardedCache[K, V]) StartJanitor(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		s.runJanitor(ticker.C, done)
+		close(stopped)
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+		<-stopped
+	}
+}
+
+func (s *ShardedCache[K, V]) runJanitor(tick <-chan time.Time, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-tick:
+			s.RemoveExpired()
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestShardedCacheTTL(t *testing.T) {
+	assert := assert.New(t)
+	clock := newFakeClock()
+	c := NewSharded[int, int](4, 64)
+	c.SetClock(clock)
+
+	for i := 0; i < 8; i++ {
+		c.SetWithTTL(i, i, time.Duration(i+1)*time.Second)
+	}
+
+	clock.Advance(4 * time.Second)
+	assert.Equal(4, c.RemoveExpired(), "keys 0-3 should have expired")
+	assert.Equal(4, c.Len())
+
+	_, ok := c.Get(4)
+	assert.True(ok, "Get(4) should hit until its ttl elapses")
+}
+
+func TestJanitorSweepsOnTick(t *testing.T) {
+	assert := assert.New(t)
+	clock := newFakeClock()
+	c := NewSharded[int, int](4, 16)
+	c.SetClock(clock)
+
+	c.SetWithTTL(1, 1, time.Second)
+	c.Set(2, 2)
+
+	tick := make(chan time.Time)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		c.runJanitor(tick, done)
+		close(stopped)
+	}()
+
+	clock.Advance(time.Second)
+	// the second send only completes after the first sweep has finished
+	tick <- clock.Now()
+	tick <- clock.Now()
+	close(done)
+	<-stopped
+
+	assert.Equal(1, c.Len(), "the janitor should have removed the expired entry")
+}
+
+func TestStartJanitorStop(t *testing.T) {
+	assert := assert.New(t)
+	clock := newFakeClock()
+	c := NewSharded[int, int](2, 4)
+	c.SetClock(clock)
+	stop := c.StartJanitor(time.Millisecond)
+
+	c.SetWithTTL(1, 1, time.Second)
+	clock.Advance(time.Second)
+	assert.Eventually(func() bool { return c.Len() == 0 }, time.Second, time.Millisecond,
+		"the running janitor should remove the expired entry")
+
+	stop()
+	stop()
+
+	// once stopped, expired entries stay until they are looked up
+	c.SetWithTTL(2, 2, time.Second)
+	clock.Advance(time.Second)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(1, c.Len(), "a stopped janitor should not sweep")
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"container/list"
+)
+
+// lfuItem tracks the access count of a resident key.
+type lfuItem[K comparable] struct {
+	key       K
+	frequency int
+}
+
+// lfuPolicy evicts the least frequently used key, breaking ties by
+// evicting the least recently used key among those with equal counts.
+type lfuPolicy[K comparable] struct {
+	hash        map[K]*list.Element
+	frequencies map[int]*list.List
+	minimum     int
+}
+
+// NewLFUPolicy returns a least frequently used eviction policy.
+func NewLFUPolicy[K comparable]() Policy[K] {
+	return &lfuPolicy[K]{
+		hash:        make(map[K]*list.Element),
+		frequencies: make(map[int]*list.List),
+	}
+}
+
+func (p *lfuPolicy[K]) Admit(key K) {
+	p.push(&lfuItem[K]{key: key, frequency: 1})
+	p.minimum = 1
+}
+
+func (p *lfuPolicy[K]) Access(key K) {
+	element, ok := p.hash[key]
+	if !ok {
+		return
+	}
+
+	// move the key up into the next frequency bucket
+	item := p.unlink(element)
+	item.frequency++
+	p.push(item)
+	if p.minimum == item.frequency-1 && p.frequencies[p.minimum] == nil {
+		p.minimum = item.frequency
+	}
+}
+
+func (p *lfuPolicy[K]) Remove(key K) {
+	element, ok := p.hash[key]
+	if !ok {
+		return
+	}
+
+	// the minimum is recomputed lazily by Victim if its bucket emptied
+	p.unlink(element)
+}
+
+func (p *lfuPolicy[K]) Victim(incoming K) (K, bool) {
+	if p.frequencies[p.minimum] == nil {
+		p.recomputeMinimum()
+	}
+
+	bucket := p.frequencies[p.minimum]
+	if bucket == nil {
+		var zero K
+		return zero, false
+	}
+
+	return p.unlink(bucket.Back()).key, true
+}
+
+func (p *lfuPolicy[K]) push(item *lfuItem[K]) {
+	bucket, ok := p.frequencies[item.frequency]
+	if !ok {
+		bucket = list.New()
+		p.frequencies[item.frequency] = bucket
+	}
+	p.hash[item.key] = bucket.PushFront(item)
+}
+
+// unlink removes the element from its bucket, dropping empty buckets.
+func (p *lfuPolicy[K]) unlink(element *list.Element) *lfuItem[K] {
+	item := element.Value.(*lfuItem[K])
+	bucket := p.frequencies[item.frequency]
+	bucket.Remove(element)
+	if bucket.Len() == 0 {
+		delete(p.frequencies, item.frequency)
+	}
+	delete(p.hash, item.key)
+	return item
+}
+
+func (p *lfuPolicy[K]) recomputeMinimum() {
+	p.minimum = 0
+	for frequency := range p.frequencies {
+		if p.minimum == 0 || frequency < p.minimum {
+			p.minimum = frequency
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by a Loader when the backing store has no
+// value for a key. With negative caching enabled the miss is remembered
+// so the store is not asked again until NegativeTTL elapses.
+var ErrNotFound = errors.New("key not found")
+
+// Loader fetches the value for key from the backing store on a miss.
+type Loader[K comparable, V any] func(key K) (V, error)
+
+// Writer persists a value to the backing store for write-through.
+type Writer[K comparable, V any] func(key K, value V) error
+
+// LoadingOptions configures a LoadingCache. Zero values disable the
+// corresponding feature.
+type LoadingOptions[K comparable, V any] struct {
+	// TTL is how long loaded values stay cached.
+	TTL time.Duration
+	// NegativeTTL is how long ErrNotFound results stay cached.
+	NegativeTTL time.Duration
+	// Writer, if set, is called by Set before the value is cached.
+	Writer Writer[K, V]
+}
+
+// loaded is a cached load result, either a value or a known miss.
+type loaded[V any] struct {
+	value   V
+	missing bool
+}
+
+// loadCall is an in-flight load that concurrent misses wait on.
+type loadCall[V any] struct {
+	done      chan struct{}
+	value     V
+	err       error
+	forgotten bool
+}
+
+// LoadingCache is a concurrency-safe read-through cache in front of a
+// slow backing store. Concurrent misses for the same key share a single
+// call to the Loader.
+type LoadingCache[K comparable, V any] struct {
+	cache   *ShardedCache[K, loaded[V]]
+	loader  Loader[K, V]
+	options LoadingOptions[K, V]
+
+	mu    sync.Mutex
+	calls map[K]*loadCall[V]
+}
+
+// NewLoading creates a loading cache backed by a sharded LRU cache.
+func NewLoading[K comparable, V any](shards int, capacity int, loader Loader[K, V], options LoadingOptions[K, V]) *LoadingCache[K, V] {
+	return &LoadingCache[K, V]{
+		cache:   NewSharded[K, loaded[V]](shards, capacity),
+		loader:  loader,
+		options: options,
+		calls:   make(map[K]*loadCall[V]),
+	}
+}
+
+// Get returns the cached value for key, loading it on a miss. A key the
+// backing store does not have is reported as ErrNotFound.
+func (l *LoadingCache[K, V]) Get(key K) (V, error) {
+	if item, ok := l.cache.Get(key); ok {
+		if item.missing {
+			var zero V
+			return zero, ErrNotFound
+		}
+		return item.value, nil
+	}
+	return l.load(key)
+}
+
+// Set writes the value through to the backing store when a Writer is
+// configured and then caches it. Nothing is cached if the write fails.
+func (l *LoadingCache[K, V]) Set(key K, value V) error {
+	if l.options.Writer != nil {
+		if err := l.options.Writer(key, value); err != nil {
+			return err
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.forget(key)
+	return l.cache.SetWithTTL(key, loaded[V]{value: value}, l.options.TTL)
+}
+
+// Delete drops key from the cache, including a remembered miss, so the
+// next Get loads it again. The backing store is not modified.
+func (l *LoadingCache[K, V]) Delete(key K) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.forget(key)
+	return l.cache.Delete(key)
+}
+
+// Stats returns the counters of the underlying cache.
+func (l *LoadingCache[K, V]) Stats() Stats {
+	return l.cache.Stats()
+}
+
+// SetClock replaces the clock used to expire loaded values.
+func (l *LoadingCache[K, V]) SetClock(clock Clock) {
+	l.cache.SetClock(clock)
+}
+
+func (l *LoadingCache[K, V]) load(key K) (V, error) {
+	l.mu.Lock()
+	if call, ok := l.calls[key]; ok {
+		// another goroutine is already loading this key, wait for it
+		l.mu.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+
+	call := &loadCall[V]{
+		done: make(chan struct{}),
+		err:  errors.New("loader panicked"),
+	}
+	l.calls[key] = call
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		// a Set or Delete while loading makes the result stale
+		if !call.forgotten {
+			delete(l.calls, key)
+			l.store(key, call.value, call.err)
+		}
+		close(call.done)
+	}()
+
+	call.value, call.err = l.loader(key)
+	return call.value, call.err
+}
+
+func (l *LoadingCache[K, V]) store(key K, value V, err error) {
+	switch {
+	case err == nil:
+		l.cache.SetWithTTL(key, loaded[V]{value: value}, l.options.TTL)
+	case errors.Is(err, ErrNotFound) && l.options.NegativeTTL > 0:
+		l.cache.SetWithTTL(key, loaded[V]{missing: true}, l.options.NegativeTTL)
+	}
+}
+
+// forget detaches any in-flight load of key so its result is not cached.
+// The caller must hold l.mu.
+func (l *LoadingCache[K, V]) forget(key K) {
+	if call, ok := l.calls[key]; ok {
+		call.forgotten = true
+		delete(l.calls, key)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeStore is a backing store that counts how often it is read.
+type fakeStore struct {
+	mu    sync.Mutex
+	data  map[string]string
+	loads atomic.Int32
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{data: map[string]string{"a": "apple"}}
+}
+
+func (s *fakeStore) Load(key string) (string, error) {
+	s.loads.Add(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if val, ok := s.data[key]; ok {
+		return val, nil
+	}
+	return "", fmt.Errorf("load %q: %w", key, ErrNotFound)
+}
+
+func (s *fakeStore) Write(key string, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = value
+	return nil
+}
+
+func TestLoadingCacheReadThrough(t *testing.T) {
+	assert := assert.New(t)
+	store := newFakeStore()
+	c := NewLoading[string, string](4, 16, store.Load, LoadingOptions[string, string]{})
+
+	val, err := c.Get("a")
+	assert.NoError(err)
+	assert.Equal("apple", val)
+
+	val, err = c.Get("a")
+	assert.NoError(err)
+	assert.Equal("apple", val)
+	assert.Equal(int32(1), store.loads.Load(), "the second Get should be served from the cache")
+
+	_, err = c.Get("missing")
+	assert.ErrorIs(err, ErrNotFound)
+	_, err = c.Get("missing")
+	assert.ErrorIs(err, ErrNotFound)
+	assert.Equal(int32(3), store.loads.Load(), "misses should not be cached without a NegativeTTL")
+}
+
+func TestLoadingCacheCoalescesConcurrentMisses(t *testing.T) {
+	assert := assert.New(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var loads atomic.Int32
+
+	c := NewLoading[string, string](4, 16, func(key string) (string, error) {
+		if loads.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		return "value-" + key, nil
+	}, LoadingOptions[string, string]{})
+
+	var wg sync.WaitGroup
+	results := make([]string, 20)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = c.Get("k")
+		}(i)
+	}
+
+	<-started
+	close(release)
+	wg.Wait()
+
+	assert.Equal(int32(1), loads.Load(), "concurrent misses should share a single load")
+	for _, result := range results {
+		assert.Equal("value-k", result)
+	}
+}
+
+func TestLoadingCacheNegativeCaching(t *testing.T) {
+	assert := assert.New(t)
+	clock := newFakeClock()
+	store := newFakeStore()
+	c := NewLoading[string, string](4, 16, store.Load, LoadingOptions[string, string]{
+		NegativeTTL: time.Minute,
+	})
+	c.SetClock(clock)
+
+	_, err := c.Get("b")
+	assert.ErrorIs(err, ErrNotFound)
+	store.Write("b", "banana")
+
+	_, err = c.Get("b")
+	assert.ErrorIs(err, ErrNotFound, "the remembered miss should be served until it expires")
+	assert.Equal(int32(1), store.loads.Load())
+
+	clock.Advance(time.Minute)
+	val, err := c.Get("b")
+	assert.NoError(err)
+	assert.Equal("banana", val)
+}
+
+func TestLoadingCacheDoesNotCacheErrors(t *testing.T) {
+	assert := assert.New(t)
+	failure := errors.New("store unavailable")
+	var loads int
+	c := NewLoading[string, string](1, 4, func(key string) (string, error) {
+		loads++
+		return "", failure
+	}, LoadingOptions[string, string]{NegativeTTL: time.Minute})
+
+	_, err := c.Get("a")
+	assert.ErrorIs(err, failure)
+	_, err = c.Get("a")
+	assert.ErrorIs(err, failure)
+	assert.Equal(2, loads)
+}
+
+func TestLoadingCacheWriteThrough(t *testing.T) {
+	assert := assert.New(t)
+	store := newFakeStore()
+	c := NewLoading[string, string](4, 16, store.Load, LoadingOptions[string, string]{
+		Writer: store.Write,
+	})
+
+	assert.NoError(c.Set("c", "cherry"))
+	assert.Equal("cherry", store.data["c"])
+
+	val, err := c.Get("c")
+	assert.NoError(err)
+	assert.Equal("cherry", val)
+	assert.Equal(int32(0), store.loads.Load(), "a written value should be cached")
+
+	failure := errors.New("write failed")
+	c = NewLoading[string, string](4, 16, store.Load, LoadingOptions[string, string]{
+		Writer: func(string, string) error { return failure },
+	})
+	assert.ErrorIs(c.Set("d", "date"), failure)
+	_, err = c.Get("d")
+	assert.ErrorIs(err, ErrNotFound, "a failed write should not be cached")
+}
+
+func TestLoadingCacheSetDuringLoadWins(t *testing.T) {
+	assert := assert.New(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	c := NewLoading[string, string](4, 16, func(key string) (string, error) {
+		close(started)
+		<-release
+		return "stale", nil
+	}, LoadingOptions[string, string]{})
+
+	done := make(chan string)
+	go func() {
+		val, _ := c.Get("k")
+		done <- val
+	}()
+
+	<-started
+	assert.NoError(c.Set("k", "fresh"))
+	close(release)
+	assert.Equal("stale", <-done, "the caller that triggered the load sees its result")
+
+	val, err := c.Get("k")
+	assert.NoError(err)
+	assert.Equal("fresh", val, "the stale load should not overwrite the newer Set")
+}
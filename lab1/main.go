@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const usage = `usage:
+  go run . server 4001-4003 [capacity]
+  go run . client 4001-4003 "1->A,2->B,3->C"`
+
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+
+	// get the start and end ports
+	startEndPort := strings.Split(os.Args[2], "-")
+	startPort, _ := strconv.Atoi(startEndPort[0])
+	endPort, _ := strconv.Atoi(startEndPort[len(startEndPort)-1])
+
+	switch os.Args[1] {
+	case "server":
+		capacity := 1024
+		if len(os.Args) > 3 {
+			capacity, _ = strconv.Atoi(os.Args[3])
+		}
+
+		// create a ports array to store all the ports
+		ports := make([]int, 0)
+		for i := startPort; i <= endPort; i++ {
+			ports = append(ports, i)
+		}
+
+		// start a cache node on every port
+		NewCacheHTTPServer(ports, capacity).Start()
+	case "client":
+		if len(os.Args) < 4 {
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+
+		urls := make([]string, 0)
+		for i := startPort; i <= endPort; i++ {
+			urls = append(urls, fmt.Sprintf("http://localhost:%d", i))
+		}
+		client := NewCacheClient(urls)
+
+		keyValuePairs := strings.Split(os.Args[3], ",")
+		for _, pair := range keyValuePairs {
+			keyValue := strings.Split(pair, "->")
+			if len(keyValue) != 2 {
+				fmt.Println("skipping malformed pair:", pair)
+				continue
+			}
+
+			fmt.Printf("Sending %s to %s\n", pair, client.NodeFor(keyValue[0]))
+			if err := client.Set(keyValue[0], keyValue[1], 0); err != nil {
+				fmt.Println("Request failed:", err)
+			}
+		}
+	default:
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// IslandMetrics describes the size of a single island. Area is the
+// number of land cells and Perimeter the number of cell edges that
+// border water or the edge of the grid, including the shores of lakes.
+type IslandMetrics struct {
+	Label     int
+	Area      int
+	Perimeter int
+	Bounds    Bounds
+}
+
+// Measure returns the area and perimeter of every island, in label order.
+func Measure(grid [][]int, conn Connectivity) []IslandMetrics {
+	components := Components(grid, conn)
+	metrics := make([]IslandMetrics, len(components))
+
+	for i, component := range components {
+		perimeter := 0
+		for _, cell := range component.Cells {
+			// every side not shared with another land cell is coastline
+			for _, offset := range neighbourOffsets[FourConnected] {
+				if !isLand(grid, cell.Row+offset.Row, cell.Col+offset.Col) {
+					perimeter++
+				}
+			}
+		}
+
+		metrics[i] = IslandMetrics{
+			Label:     component.Label,
+			Area:      component.Size,
+			Perimeter: perimeter,
+			Bounds:    component.Bounds,
+		}
+	}
+
+	return metrics
+}
+
+// Lakes returns the water regions that are completely enclosed by land,
+// i.e. not connected to the border of the grid. Water flows through the
+// gaps that conn leaves open between land cells: with 4-connected land
+// it can pass diagonally, with 8-connected land it cannot.
+func Lakes(grid [][]int, conn Connectivity) []Component {
+	waterConn := EightConnected
+	if conn == EightConnected {
+		waterConn = FourConnected
+	}
+
+	// flip the grid so that water cells become the regions to label
+	water := make([][]int, len(grid))
+	for i := range grid {
+		water[i] = make([]int, len(grid[i]))
+		for j := range grid[i] {
+			if grid[i][j] == 0 {
+				water[i][j] = 1
+			}
+		}
+	}
+
+	lakes := make([]Component, 0)
+	for _, component := range Components(water, waterConn) {
+		enclosed := true
+		for _, cell := range component.Cells {
+			if onBorder(grid, cell) {
+				enclosed = false
+				break
+			}
+		}
+
+		if enclosed {
+			component.Label = len(lakes) + 1
+			lakes = append(lakes, component)
+		}
+	}
+
+	return lakes
+}
+
+// onBorder reports whether the cell touches the outside of the grid,
+// including the gaps left by shorter neighbouring rows.
+func onBorder(grid [][]int, cell Cell) bool {
+	row, col := cell.Row, cell.Col
+	return row == 0 || row == len(grid)-1 ||
+		col == 0 || col >= len(grid[row])-1 ||
+		col >= len(grid[row-1]) || col >= len(grid[row+1])
+}
+
+// DistinctShapes returns the number of different island shapes, where
+// two islands have the same shape if one can be translated, rotated by
+// a multiple of 90 degrees or mirrored onto the other.
+func DistinctShapes(grid [][]int, conn Connectivity) int {
+	shapes := make(map[string]bool)
+	for _, component := range Components(grid, conn) {
+		shapes[canonicalShape(component.Cells)] = true
+	}
+	return len(shapes)
+}
+
+// symmetries are the eight rotations and reflections of the square.
+var symmetries = []func(Cell) Cell{
+	func(c Cell) Cell { return Cell{c.Row, c.Col} },
+	func(c Cell) Cell { return Cell{c.Row, -c.Col} },
+	func(c Cell) Cell { return Cell{-c.Row, c.Col} },
+	func(c Cell) Cell { return Cell{-c.Row, -c.Col} },
+	func(c Cell) Cell { return Cell{c.Col, c.Row} },
+	func(c Cell) Cell { return Cell{c.Col, -c.Row} },
+	func(c Cell) Cell { return Cell{-c.Col, c.Row} },
+	func(c Cell) Cell { return Cell{-c.Col, -c.Row} },
+}
+
+// canonicalShape encodes the cells so that every translation, rotation
+// and reflection of a shape gives the same string: each symmetry is
+// normalised to start at (0, 0) and the smallest encoding wins.
+func canonicalShape(cells []Cell) string {
+	best := ""
+	transformed := make([]Cell, len(cells))
+
+	for _, symmetry := range symmetries {
+		minRow, minCol := 0, 0
+		for i, cell := range cells {
+			transformed[i] = symmetry(cell)
+			if i == 0 || transformed[i].Row < minRow {
+				minRow = transformed[i].Row
+			}
+			if i == 0 || transformed[i].Col < minCol {
+				minCol = transformed[i].Col
+			}
+		}
+
+		sort.Slice(transformed, func(i, j int) bool {
+			if transformed[i].Row != transformed[j].Row {
+				return transformed[i].Row < transformed[j].Row
+			}
+			return transformed[i].Col < transformed[j].Col
+		})
+
+		var b strings.Builder
+		for _, cell := range transformed {
+			fmt.Fprintf(&b, "%d,%d;", cell.Row-minRow, cell.Col-minCol)
+		}
+
+		if encoding := b.String(); best == "" || encoding < best {
+			best = encoding
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMeasure(t *testing.T) {
+	tests := []struct {
+		name string
+		grid [][]int
+		conn Connectivity
+		want []IslandMetrics
+	}{
+		{
+			name: "single cell",
+			grid: [][]int{{1}},
+			conn: FourConnected,
+			want: []IslandMetrics{{Label: 1, Area: 1, Perimeter: 4, Bounds: Bounds{0, 0, 0, 0}}},
+		},
+		{
+			name: "lab grid",
+			grid: [][]int{
+				{1, 1, 0, 0, 0},
+				{1, 1, 0, 0, 0},
+				{0, 0, 1, 0, 0},
+				{0, 0, 0, 1, 1},
+			},
+			conn: FourConnected,
+			want: []IslandMetrics{
+				{Label: 1, Area: 4, Perimeter: 8, Bounds: Bounds{0, 0, 1, 1}},
+				{Label: 2, Area: 1, Perimeter: 4, Bounds: Bounds{2, 2, 2, 2}},
+				{Label: 3, Area: 2, Perimeter: 6, Bounds: Bounds{3, 3, 3, 4}},
+			},
+		},
+		{
+			name: "diagonal cells counted as one island",
+			grid: [][]int{
+				{1, 0},
+				{0, 1},
+			},
+			conn: EightConnected,
+			want: []IslandMetrics{{Label: 1, Area: 2, Perimeter: 8, Bounds: Bounds{0, 0, 1, 1}}},
+		},
+		{
+			name: "ring includes the lake shore",
+			grid: [][]int{
+				{1, 1, 1},
+				{1, 0, 1},
+				{1, 1, 1},
+			},
+			conn: FourConnected,
+			want: []IslandMetrics{{Label: 1, Area: 8, Perimeter: 16, Bounds: Bounds{0, 0, 2, 2}}},
+		},
+		{
+			name: "no land",
+			grid: [][]int{{0, 0}},
+			conn: FourConnected,
+			want: []IslandMetrics{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, Measure(test.grid, test.conn))
+		})
+	}
+}
+
+func TestLakes(t *testing.T) {
+	tests := []struct {
+		name  string
+		grid  [][]int
+		conn  Connectivity
+		sizes []int
+	}{
+		{
+			name: "one enclosed cell",
+			grid: [][]int{
+				{1, 1, 1},
+				{1, 0, 1},
+				{1, 1, 1},
+			},
+			conn:  FourConnected,
+			sizes: []int{1},
+		},
+		{
+			name: "bay open to the border",
+			grid: [][]int{
+				{1, 1, 1},
+				{1, 0, 0},
+				{1, 1, 1},
+			},
+			conn:  FourConnected,
+			sizes: []int{},
+		},
+		{
+			name: "two lakes of different sizes",
+			grid: [][]int{
+				{1, 1, 1, 1, 1, 1, 1},
+				{1, 0, 1, 0, 0, 0, 1},
+				{1, 1, 1, 0, 0, 1, 1},
+				{0, 0, 1, 1, 1, 1, 0},
+			},
+			conn:  FourConnected,
+			sizes: []int{1, 5},
+		},
+		{
+			name: "diagonal gap drains a 4-connected ring",
+			grid: [][]int{
+				{0, 1, 1, 0},
+				{1, 0, 0, 1},
+				{1, 0, 0, 1},
+				{0, 1, 1, 0},
+			},
+			conn:  FourConnected,
+			sizes: []int{},
+		},
+		{
+			name: "diagonal gap is sealed by an 8-connected ring",
+			grid: [][]int{
+				{0, 1, 1, 0},
+				{1, 0, 0, 1},
+				{1, 0, 0, 1},
+				{0, 1, 1, 0},
+			},
+			conn:  EightConnected,
+			sizes: []int{4},
+		},
+		{
+			name: "short row opens the lake",
+			grid: [][]int{
+				{1, 1, 1},
+				{1, 0, 1},
+				{1},
+			},
+			conn:  FourConnected,
+			sizes: []int{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sizes := make([]int, 0)
+			for i, lake := range Lakes(test.grid, test.conn) {
+				assert.Equal(t, i+1, lake.Label)
+				sizes = append(sizes, lake.Size)
+			}
+			assert.Equal(t, test.sizes, sizes)
+		})
+	}
+}
+
+func TestDistinctShapes(t *testing.T) {
+	tests := []struct {
+		name string
+		grid [][]int
+		want int
+	}{
+		{
+			name: "empty",
+			grid: [][]int{{0}},
+			want: 0,
+		},
+		{
+			name: "translated copies",
+			grid: [][]int{
+				{1, 1, 0, 1, 1},
+				{1, 0, 0, 1, 0},
+			},
+			want: 1,
+		},
+		{
+			name: "rotations of an L",
+			grid: [][]int{
+				{1, 0, 0, 1, 1, 0, 1, 1},
+				{1, 1, 0, 0, 1, 0, 1, 0},
+			},
+			want: 1,
+		},
+		{
+			name: "mirror images of an S tetromino",
+			grid: [][]int{
+				{0, 1, 1, 0, 1, 1, 0, 0},
+				{1, 1, 0, 0, 0, 1, 1, 0},
+			},
+			want: 1,
+		},
+		{
+			name: "two line lengths, a square and a single cell",
+			grid: [][]int{
+				{1, 1, 1, 1, 0, 1, 1},
+				{0, 0, 0, 0, 0, 1, 1},
+				{1, 0, 1, 1, 1, 0, 0},
+			},
+			want: 4,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, DistinctShapes(test.grid, FourConnected))
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// stripe is a band of consecutive rows labelled by a single goroutine.
+// first and last hold the local labels of its top and bottom rows.
+type stripe struct {
+	start int
+	end   int
+	count int
+	first []int
+	last  []int
+}
+
+// CountIslandsParallel returns the same count as CountIslandsWith but
+// splits the grid into horizontal stripes that are labelled
+// concurrently by up to workers goroutines. A workers value of zero or
+// less uses GOMAXPROCS.
+func CountIslandsParallel(grid [][]int, conn Connectivity, workers int) int {
+	_, count := labelParallel(grid, conn, workers, false)
+	return count
+}
+
+// LabelParallel returns the same labels and count as Label, computed
+// over concurrently labelled stripes.
+func LabelParallel(grid [][]int, conn Connectivity, workers int) ([][]int, int) {
+	return labelParallel(grid, conn, workers, true)
+}
+
+func labelParallel(grid [][]int, conn Connectivity, workers int, keepLabels bool) ([][]int, int) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = max(1, min(workers, len(grid)))
+
+	var labels [][]int
+	if keepLabels {
+		labels = make([][]int, len(grid))
+		for i := range grid {
+			labels[i] = make([]int, len(grid[i]))
+		}
+	}
+
+	// split the rows as evenly as possible
+	stripes := make([]*stripe, workers)
+	for i := range stripes {
+		stripes[i] = &stripe{
+			start: i * len(grid) / workers,
+			end:   (i + 1) * len(grid) / workers,
+		}
+	}
+
+	// label every stripe on its own, as if it were a separate grid
+	var wg sync.WaitGroup
+	for _, s := range stripes {
+		wg.Add(1)
+		go func(s *stripe) {
+			defer wg.Done()
+			if s.start == s.end {
+				return
+			}
+			s.first = make([]int, len(grid[s.start]))
+			s.last = make([]int, len(grid[s.end-1]))
+
+			s.count = floodFill(grid[s.start:s.end], conn, func(label int, cell Cell) {
+				if keepLabels {
+					labels[s.start+cell.Row][cell.Col] = label
+				}
+				if cell.Row == 0 {
+					s.first[cell.Col] = label
+				}
+				if cell.Row == s.end-s.start-1 {
+					s.last[cell.Col] = label
+				}
+			})
+		}(s)
+	}
+	wg.Wait()
+
+	// give every local label a global id: stripe offset + label - 1
+	offsets := make([]int, len(stripes))
+	total := 0
+	for i, s := range stripes {
+		offsets[i] = total
+		total += s.count
+	}
+
+	// merge components that touch across each stripe boundary
+	sets := newUnionFind(total)
+	count := total
+	for i := 1; i < len(stripes); i++ {
+		above, below := stripes[i-1], stripes[i]
+		if above.last == nil || below.first == nil {
+			continue
+		}
+
+		for col, label := range below.first {
+			if label == 0 {
+				continue
+			}
+			for _, offset := range neighbourOffsets[conn] {
+				if offset.Row != -1 {
+					continue
+				}
+				neighbour := col + offset.Col
+				if neighbour < 0 || neighbour >= len(above.last) || above.last[neighbour] == 0 {
+					continue
+				}
+				if sets.union(offsets[i]+label-1, offsets[i-1]+above.last[neighbour]-1) {
+					count--
+				}
+			}
+		}
+	}
+
+	if !keepLabels {
+		return nil, count
+	}
+
+	// local labels already follow row-major order within each stripe, so
+	// numbering roots in stripe then label order matches Label exactly
+	canonical := make([]int, total)
+	relabel := make([]int, total)
+	next := 0
+	for id := 0; id < total; id++ {
+		root := sets.find(id)
+		if canonical[root] == 0 {
+			next++
+			canonical[root] = next
+		}
+		relabel[id] = canonical[root]
+	}
+
+	// find mutates the forest, so the goroutines only read relabel
+	for i, s := range stripes {
+		wg.Add(1)
+		go func(i int, s *stripe) {
+			defer wg.Done()
+			for row := s.start; row < s.end; row++ {
+				for col, label := range labels[row] {
+					if label != 0 {
+						labels[row][col] = relabel[offsets[i]+label-1]
+					}
+				}
+			}
+		}(i, s)
+	}
+	wg.Wait()
+
+	return labels, count
+}
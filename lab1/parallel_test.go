@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func randomGrid(r *rand.Rand, rows int, cols int, density float64) [][]int {
+	grid := make([][]int, rows)
+	for i := range grid {
+		grid[i] = make([]int, cols)
+		for j := range grid[i] {
+			if r.Float64() < density {
+				grid[i][j] = 1
+			}
+		}
+	}
+	return grid
+}
+
+// TestParallelMatchesSequential is a property test: for random grids of
+// many shapes and densities the parallel labelling must agree exactly
+// with the sequential one for every worker count.
+func TestParallelMatchesSequential(t *testing.T) {
+	r := rand.New(rand.NewSource(11))
+	for trial := 0; trial < 200; trial++ {
+		rows, cols := 1+r.Intn(40), 1+r.Intn(40)
+		grid := randomGrid(r, rows, cols, r.Float64())
+
+		for _, conn := range []Connectivity{FourConnected, EightConnected} {
+			wantLabels, wantCount := Label(grid, conn)
+			for _, workers := range []int{1, 2, 3, 7, 64} {
+				name := fmt.Sprintf("trial %d, %dx%d, %d-connected, %d workers", trial, rows, cols, conn, workers)
+				assert.Equal(t, wantCount, CountIslandsParallel(grid, conn, workers), name)
+
+				labels, count := LabelParallel(grid, conn, workers)
+				assert.Equal(t, wantCount, count, name)
+				if !assert.Equal(t, wantLabels, labels, name) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func TestParallelEdgeCases(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(0, CountIslandsParallel([][]int{}, FourConnected, 4))
+	assert.Equal(3, CountIslandsParallel([][]int{{1, 0, 1}, {0}, {1, 1}}, FourConnected, 0))
+
+	// a single island snaking across every stripe boundary
+	grid := [][]int{
+		{1, 1, 1},
+		{0, 0, 1},
+		{1, 1, 1},
+		{1, 0, 0},
+		{1, 1, 1},
+	}
+	assert.Equal(1, CountIslandsParallel(grid, FourConnected, 5))
+}
+
+func BenchmarkCountIslands(b *testing.B) {
+	grid := randomGrid(rand.New(rand.NewSource(1)), 2000, 2000, 0.5)
+
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			CountIslands(grid)
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			CountIslandsParallel(grid, FourConnected, 0)
+		}
+	})
+}
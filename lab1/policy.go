@@ -0,0 +1,61 @@
+package main
+
+import (
+	"container/list"
+)
+
+// Policy decides which resident key the cache evicts once it is full.
+// The cache calls Admit for every new key, Access on every hit or
+// update, and Remove when a key is deleted or expires. Victim is called
+// before incoming is admitted; it must pick a resident key, stop
+// tracking it and return it, or report false if nothing is resident.
+// Policies are not safe for concurrent use.
+type Policy[K comparable] interface {
+	Admit(key K)
+	Access(key K)
+	Remove(key K)
+	Victim(incoming K) (K, bool)
+}
+
+// lruPolicy evicts the least recently used key.
+type lruPolicy[K comparable] struct {
+	hash  map[K]*list.Element
+	queue *list.List
+}
+
+// NewLRUPolicy returns a least recently used eviction policy.
+func NewLRUPolicy[K comparable]() Policy[K] {
+	return &lruPolicy[K]{
+		hash:  make(map[K]*list.Element),
+		queue: list.New(),
+	}
+}
+
+func (p *lruPolicy[K]) Admit(key K) {
+	p.hash[key] = p.queue.PushFront(key)
+}
+
+func (p *lruPolicy[K]) Access(key K) {
+	if element, ok := p.hash[key]; ok {
+		p.queue.MoveToFront(element)
+	}
+}
+
+func (p *lruPolicy[K]) Remove(key K) {
+	if element, ok := p.hash[key]; ok {
+		p.queue.Remove(element)
+		delete(p.hash, key)
+	}
+}
+
+func (p *lruPolicy[K]) Victim(incoming K) (K, bool) {
+	lastElement := p.queue.Back()
+	if lastElement == nil {
+		var zero K
+		return zero, false
+	}
+
+	key := p.queue.Remove(lastElement).(K)
+	delete(p.hash, key)
+	return key, true
+}
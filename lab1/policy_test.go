@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+var policies = []struct {
+	name      string
+	newPolicy func(capacity int) Policy[int]
+}{
+	{"LRU", func(int) Policy[int] { return NewLRUPolicy[int]() }},
+	{"LFU", func(int) Policy[int] { return NewLFUPolicy[int]() }},
+	{"2Q", func(capacity int) Policy[int] { return NewTwoQueuePolicy[int](capacity) }},
+	{"ARC", func(capacity int) Policy[int] { return NewARCPolicy[int](capacity) }},
+}
+
+// TestPolicyConformance runs the same behavioural checks against every
+// eviction policy, starting with the scenario from TestCache.
+func TestPolicyConformance(t *testing.T) {
+	for _, p := range policies {
+		newCache := func(capacity int) *LRUCache[int, int] {
+			return NewWithPolicy[int, int](capacity, p.newPolicy(capacity))
+		}
+
+		t.Run(p.name+"/TestCache", func(t *testing.T) {
+			assert := assert.New(t)
+			c := newCache(CACHE_SIZE)
+			c.Set(1, 10)
+			c.Set(2, 20)
+			c.Set(3, 30)
+
+			assertGet(assert, c, 1, 10)
+			assertGet(assert, c, 2, 20)
+			assertGet(assert, c, 3, 30)
+
+			c.Set(4, 40)
+			assertGet(assert, c, 4, 40)
+			_, ok := c.Get(1)
+			assert.False(ok, "Get(1) should miss as it was the oldest equally used entry")
+		})
+
+		t.Run(p.name+"/UpdateInPlace", func(t *testing.T) {
+			assert := assert.New(t)
+			c := newCache(CACHE_SIZE)
+			c.Set(1, 10)
+			c.Set(1, 11)
+			assert.Equal(1, c.Len())
+			assertGet(assert, c, 1, 11)
+		})
+
+		t.Run(p.name+"/DeleteFreesSlot", func(t *testing.T) {
+			assert := assert.New(t)
+			c := newCache(CACHE_SIZE)
+			c.Set(1, 10)
+			c.Set(2, 20)
+			c.Set(3, 30)
+			assert.True(c.Delete(2))
+
+			c.Set(4, 40)
+			assert.Equal(CACHE_SIZE, c.Len())
+			assertGet(assert, c, 1, 10)
+			assertGet(assert, c, 3, 30)
+			assertGet(assert, c, 4, 40)
+		})
+
+		t.Run(p.name+"/RandomOperations", func(t *testing.T) {
+			assert := assert.New(t)
+			c := newCache(16)
+			r := rand.New(rand.NewSource(1))
+			model := make(map[int]int)
+
+			for i := 0; i < 20000; i++ {
+				key := r.Intn(64)
+				switch r.Intn(4) {
+				case 0:
+					c.Delete(key)
+					delete(model, key)
+				case 1:
+					if val, ok := c.Get(key); ok {
+						assert.Equal(model[key], val, "Get(%d) returned a stale value", key)
+					}
+				default:
+					c.Set(key, i)
+					model[key] = i
+				}
+				if c.Len() > c.Cap() {
+					t.Fatalf("cache holds %d entries, capacity is %d", c.Len(), c.Cap())
+				}
+			}
+
+			// every resident entry must still be reachable
+			stats := c.Stats()
+			assert.Equal(c.Len(), stats.Size)
+		})
+	}
+}
+
+func TestLFUKeepsFrequentKeys(t *testing.T) {
+	assert := assert.New(t)
+	c := NewWithPolicy[int, int](CACHE_SIZE, NewLFUPolicy[int]())
+
+	c.Set(1, 10)
+	c.Get(1)
+	c.Get(1)
+	c.Set(2, 20)
+	c.Get(2)
+	c.Set(3, 30)
+	c.Set(4, 40)
+
+	_, ok := c.Get(3)
+	assert.False(ok, "the least frequently used key should be evicted")
+	assertGet(assert, c, 1, 10)
+	assertGet(assert, c, 2, 20)
+}
+
+// TestPolicyUpdateAtCapacity grows an existing key so that something has
+// to be evicted: whichever key the policy picks, the updated one stays.
+func TestPolicyUpdateAtCapacity(t *testing.T) {
+	for _, p := range policies {
+		t.Run(p.name, func(t *testing.T) {
+			assert := assert.New(t)
+			valueSizer := func(key int, value int) int { return value }
+			c := NewSizedWithPolicy[int, int](3, valueSizer, p.newPolicy(3))
+
+			for key := 1; key <= 3; key++ {
+				assert.NoError(c.Set(key, 1))
+			}
+			// make 1 the coldest key
+			for i := 0; i < 3; i++ {
+				c.Get(2)
+				c.Get(3)
+			}
+
+			assert.NoError(c.Set(1, 2))
+			value, ok := c.Get(1)
+			assert.True(ok, "the updated key was evicted")
+			assert.Equal(2, value)
+			assert.Equal(2, c.Len())
+			assert.Equal(3, c.Stats().Cost)
+
+			// the key is still tracked by the policy and can be evicted later
+			assert.NoError(c.Set(4, 3))
+			_, ok = c.Get(1)
+			assert.False(ok)
+			assert.Equal(1, c.Len())
+		})
+	}
+}
+
+// a one-off scan larger than the cache should not flush keys that were
+// referenced more than once from the scan resistant policies.
+func TestScanResistance(t *testing.T) {
+	for _, p := range policies[2:] {
+		t.Run(p.name, func(t *testing.T) {
+			assert := assert.New(t)
+			c := NewWithPolicy[int, int](8, p.newPolicy(8))
+			touch := func(key int) {
+				if _, ok := c.Get(key); !ok {
+					c.Set(key, key)
+				}
+			}
+
+			// reference the working set, let some cold keys pass
+			// through and then reference the working set again
+			for key := 0; key < 4; key++ {
+				touch(key)
+				touch(key)
+			}
+			for key := 100; key < 108; key++ {
+				touch(key)
+			}
+			for key := 0; key < 4; key++ {
+				touch(key)
+			}
+
+			// a long sequential scan of keys never seen again
+			for key := 1000; key < 1100; key++ {
+				touch(key)
+			}
+
+			for key := 0; key < 4; key++ {
+				_, ok := c.Get(key)
+				assert.True(ok, "Get(%d) should survive the scan", key)
+			}
+		})
+	}
+}
+
+// BenchmarkPolicyHitRatio replays synthetic Zipf traces, with and
+// without interleaved scans, and reports the hit ratio of each policy.
+func BenchmarkPolicyHitRatio(b *testing.B) {
+	const keySpace, capacity, traceLen = 10000, 500, 200000
+
+	traces := map[string][]int{}
+	r := rand.New(rand.NewSource(42))
+	zipf := rand.NewZipf(r, 1.1, 1, keySpace-1)
+
+	zipfTrace := make([]int, traceLen)
+	for i := range zipfTrace {
+		zipfTrace[i] = int(zipf.Uint64())
+	}
+	traces["zipf"] = zipfTrace
+
+	// every 5000 requests a scan of 1000 unique cold keys runs through
+	scanTrace := make([]int, 0, traceLen*2)
+	scanKey := keySpace
+	for i := 0; i < traceLen; i++ {
+		scanTrace = append(scanTrace, zipfTrace[i])
+		if i%5000 == 4999 {
+			for j := 0; j < 1000; j++ {
+				scanTrace = append(scanTrace, scanKey)
+				scanKey++
+			}
+		}
+	}
+	traces["zipf+scan"] = scanTrace
+
+	for _, traceName := range []string{"zipf", "zipf+scan"} {
+		trace := traces[traceName]
+		for _, p := range policies {
+			b.Run(fmt.Sprintf("%s/%s", traceName, p.name), func(b *testing.B) {
+				var stats Stats
+				for n := 0; n < b.N; n++ {
+					c := NewWithPolicy[int, int](capacity, p.newPolicy(capacity))
+					for _, key := range trace {
+						if _, ok := c.Get(key); !ok {
+							c.Set(key, key)
+						}
+					}
+					stats = c.Stats()
+				}
+				b.ReportMetric(100*stats.HitRatio(), "hit%")
+			})
+		}
+	}
+}
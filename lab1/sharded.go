@@ -0,0 +1,155 @@
+package main
+
+import (
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// cacheShard is a single LRU partition guarded by its own lock.
+type cacheShard[K comparable, V any] struct {
+	mu    sync.Mutex
+	cache *LRUCache[K, V]
+}
+
+// ShardedCache is a concurrency-safe cache that partitions keys across
+// independently locked LRU shards, so goroutines touching different
+// shards never contend. Recency is tracked per shard, not globally.
+type ShardedCache[K comparable, V any] struct {
+	seed   maphash.Seed
+	shards []*cacheShard[K, V]
+}
+
+// NewSharded creates an LRU cache with the given number of shards
+// whose combined capacity is at least capacity entries.
+func NewSharded[K comparable, V any](shards int, capacity int) *ShardedCache[K, V] {
+	return NewShardedWithPolicy[K, V](shards, capacity, func(int) Policy[K] {
+		return NewLRUPolicy[K]()
+	})
+}
+
+// NewShardedWithPolicy is like NewSharded but each shard evicts using
+// a policy created by newPolicy for that shard's capacity.
+func NewShardedWithPolicy[K comparable, V any](shards int, capacity int, newPolicy func(capacity int) Policy[K]) *ShardedCache[K, V] {
+	if shards < 1 {
+		shards = 1
+	}
+
+	// split the capacity evenly, rounding up so no entries are lost
+	perShard := (capacity + shards - 1) / shards
+
+	s := &ShardedCache[K, V]{
+		seed:   maphash.MakeSeed(),
+		shards: make([]*cacheShard[K, V], shards),
+	}
+	for i := range s.shards {
+		s.shards[i] = &cacheShard[K, V]{cache: NewWithPolicy[K, V](perShard, newPolicy(perShard))}
+	}
+
+	return s
+}
+
+func (s *ShardedCache[K, V]) shardFor(key K) *cacheShard[K, V] {
+	h := maphash.Comparable(s.seed, key)
+	return s.shards[h%uint64(len(s.shards))]
+}
+
+// Set adds or updates the value for key in its shard.
+func (s *ShardedCache[K, V]) Set(key K, value V) error {
+	shard := s.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	return shard.cache.Set(key, value)
+}
+
+// SetWithTTL adds or updates the value for key, expiring it after ttl.
+func (s *ShardedCache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) error {
+	shard := s.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	return shard.cache.SetWithTTL(key, value, ttl)
+}
+
+// Get returns the value stored for key and whether it was found.
+func (s *ShardedCache[K, V]) Get(key K) (V, bool) {
+	shard := s.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	return shard.cache.Get(key)
+}
+
+// Delete removes key from its shard and reports whether it was present.
+func (s *ShardedCache[K, V]) Delete(key K) bool {
+	shard := s.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	return shard.cache.Delete(key)
+}
+
+// RemoveExpired sweeps expired entries out of every shard, locking one
+// shard at a time, and returns how many were removed.
+func (s *ShardedCache[K, V]) RemoveExpired() int {
+	removed := 0
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		removed += shard.cache.RemoveExpired()
+		shard.mu.Unlock()
+	}
+	return removed
+}
+
+// SetClock replaces the clock used by every shard.
+func (s *ShardedCache[K, V]) SetClock(clock Clock) {
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		shard.cache.SetClock(clock)
+		shard.mu.Unlock()
+	}
+}
+
+// OnEvict registers fn on every shard. The hook runs while the shard
+// lock is held, so it must not call back into the cache.
+func (s *ShardedCache[K, V]) OnEvict(fn func(key K, value V, reason EvictReason)) {
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		shard.cache.OnEvict(fn)
+		shard.mu.Unlock()
+	}
+}
+
+// Stats returns the counters summed across all shards.
+func (s *ShardedCache[K, V]) Stats() Stats {
+	var total Stats
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		stats := shard.cache.Stats()
+		shard.mu.Unlock()
+
+		total.Hits += stats.Hits
+		total.Misses += stats.Misses
+		total.Evictions += stats.Evictions
+		total.Size += stats.Size
+		total.Cost += stats.Cost
+	}
+	return total
+}
+
+// Len returns the total number of entries across all shards.
+func (s *ShardedCache[K, V]) Len() int {
+	total := 0
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		total += shard.cache.Len()
+		shard.mu.Unlock()
+	}
+	return total
+}
+
+// Cap returns the total capacity across all shards.
+func (s *ShardedCache[K, V]) Cap() int {
+	total := 0
+	for _, shard := range s.shards {
+		total += shard.cache.Cap()
+	}
+	return total
+}
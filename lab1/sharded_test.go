@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestShardedCache(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(12, NewSharded[int, string](4, 10).Cap(), "capacity should be rounded up to fill every shard")
+
+	// keep the cache large enough that no shard can overflow
+	c := NewSharded[int, string](4, 40)
+
+	for i := 0; i < 8; i++ {
+		c.Set(i, fmt.Sprint(i))
+	}
+	assert.Equal(8, c.Len())
+
+	for i := 0; i < 8; i++ {
+		val, ok := c.Get(i)
+		assert.True(ok, "Get(%d) should hit", i)
+		assert.Equal(fmt.Sprint(i), val)
+	}
+
+	assert.True(c.Delete(3))
+	_, ok := c.Get(3)
+	assert.False(ok, "Get(3) should miss after Delete")
+	assert.Equal(7, c.Len())
+}
+
+func TestShardedCacheBoundsEachShard(t *testing.T) {
+	assert := assert.New(t)
+	c := NewSharded[int, int](4, 16)
+
+	for i := 0; i < 1000; i++ {
+		c.Set(i, i)
+	}
+
+	assert.Equal(c.Cap(), c.Len(), "every shard should be full but never over capacity")
+	for _, shard := range c.shards {
+		assert.LessOrEqual(shard.cache.Len(), shard.cache.Cap())
+	}
+}
+
+// run with `go test -race` to detect unsynchronized access.
+func TestShardedCacheConcurrentAccess(t *testing.T) {
+	assert := assert.New(t)
+	c := NewSharded[int, int](8, 256)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for i := 0; i < 2000; i++ {
+				key := r.Intn(512)
+				switch r.Intn(3) {
+				case 0:
+					c.Set(key, key*10)
+				case 1:
+					if val, ok := c.Get(key); ok && val != key*10 {
+						t.Errorf("Get(%d) returned %d, want %d", key, val, key*10)
+					}
+				default:
+					c.Delete(key)
+				}
+			}
+		}(int64(g))
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(c.Len(), c.Cap())
+}
+
+func BenchmarkShardedCacheParallel(b *testing.B) {
+	for _, shards := range []int{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			c := NewSharded[int, int](shards, 4096)
+			for i := 0; i < 4096; i++ {
+				c.Set(i, i)
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				r := rand.New(rand.NewSource(rand.Int63()))
+				for pb.Next() {
+					key := r.Intn(8192)
+					// roughly 90% reads, 10% writes
+					if r.Intn(10) == 0 {
+						c.Set(key, key)
+					} else {
+						c.Get(key)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestShardedCacheStatsAndOnEvict(t *testing.T) {
+	assert := assert.New(t)
+	c := NewSharded[int, int](4, 4)
+
+	var mu sync.Mutex
+	reasons := make(map[EvictReason]int)
+	c.OnEvict(func(key int, value int, reason EvictReason) {
+		mu.Lock()
+		defer mu.Unlock()
+		reasons[reason]++
+	})
+
+	for i := 0; i < 100; i++ {
+		c.Set(i, i)
+	}
+	for i := 0; i < 100; i++ {
+		c.Get(i)
+	}
+
+	stats := c.Stats()
+	assert.Equal(uint64(100), stats.Hits+stats.Misses)
+	assert.Equal(uint64(stats.Size), stats.Hits, "every resident key should hit")
+	assert.Equal(uint64(100-stats.Size), stats.Evictions)
+	assert.Equal(int(stats.Evictions), reasons[EvictCapacity])
+}
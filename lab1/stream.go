@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io"
+)
+
+// CountIslandsStream counts islands while reading the grid one row at a
+// time, so only the labels of the previous and the current row are held
+// in memory. Rows may have different lengths; missing cells are water.
+//
+// Each row is labelled against the previous one with a small union-find
+// whose elements are the previous row's components plus the current
+// row's runs. A previous component that reaches no cell in the current
+// row can never grow again, so it is counted as finished.
+func CountIslandsStream(rows RowReader, conn Connectivity) (int, error) {
+	offsets, ok := neighbourOffsets[conn]
+	if !ok {
+		offsets = neighbourOffsets[FourConnected]
+	}
+
+	// above[j] is the compact label (1..components) of the previous row,
+	// or 0 for water
+	above := make([]int, 0)
+	components := 0
+	count := 0
+
+	elements := make([]int, 0)
+	current := make([]bool, 0)
+	compact := make([]int, 0)
+
+	for {
+		row, err := rows.ReadRow()
+		if err == io.EOF {
+			// everything still open at the bottom edge is finished
+			return count + components, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		// elements 0..components-1 stand for the components above
+		sets := newUnionFind(components)
+		elements = elements[:0]
+		for col, val := range row {
+			element := -1
+			if val != 0 {
+				if col > 0 && elements[col-1] >= 0 {
+					// continue the run on the left
+					element = elements[col-1]
+				} else {
+					element = sets.add()
+				}
+
+				for _, offset := range offsets {
+					neighbour := col + offset.Col
+					if offset.Row == -1 && neighbour >= 0 && neighbour < len(above) && above[neighbour] != 0 {
+						sets.union(element, above[neighbour]-1)
+					}
+				}
+			}
+			elements = append(elements, element)
+		}
+
+		// mark which sets reach the current row
+		current = resize(current, len(sets.parent))
+		clear(current)
+		for _, element := range elements {
+			if element >= 0 {
+				current[sets.find(element)] = true
+			}
+		}
+
+		// components above that reach nothing below are finished
+		for label := 0; label < components; label++ {
+			if !current[sets.find(label)] {
+				count++
+			}
+		}
+
+		// relabel the current row compactly for the next iteration
+		compact = resize(compact, len(sets.parent))
+		clear(compact)
+		components = 0
+		above = resize(above, len(elements))
+		for col, element := range elements {
+			above[col] = 0
+			if element < 0 {
+				continue
+			}
+			root := sets.find(element)
+			if compact[root] == 0 {
+				components++
+				compact[root] = components
+			}
+			above[col] = compact[root]
+		}
+	}
+}
+
+// resize returns a slice of length n, reusing s when it is big enough.
+func resize[T any](s []T, n int) []T {
+	if cap(s) < n {
+		return make([]T, n)
+	}
+	return s[:n]
+}
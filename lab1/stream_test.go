@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func TestCountIslandsStream(t *testing.T) {
+	assert := assert.New(t)
+	rows := [][]int{
+		{1, 1, 0, 0, 0},
+		{1, 1, 0, 0, 0},
+		{0, 0, 1, 0, 0},
+		{0, 0, 0, 1, 1},
+	}
+
+	count, err := CountIslandsStream(NewDenseRows(rows), FourConnected)
+	assert.NoError(err)
+	assert.Equal(3, count)
+
+	count, err = CountIslandsStream(NewDenseRows(rows), EightConnected)
+	assert.NoError(err)
+	assert.Equal(1, count)
+
+	// a U shape only joins up on its last row
+	count, err = CountIslandsStream(NewDenseRows([][]int{
+		{1, 0, 1, 0, 1},
+		{1, 0, 1, 0, 1},
+		{1, 1, 1, 0, 1},
+	}), FourConnected)
+	assert.NoError(err)
+	assert.Equal(2, count)
+}
+
+func TestCountIslandsStreamMatchesCountIslands(t *testing.T) {
+	r := rand.New(rand.NewSource(12))
+	for trial := 0; trial < 300; trial++ {
+		grid := randomGrid(r, 1+r.Intn(30), 1+r.Intn(30), r.Float64())
+		// make some rows ragged
+		for i := range grid {
+			if r.Intn(5) == 0 {
+				grid[i] = grid[i][:r.Intn(len(grid[i])+1)]
+			}
+		}
+
+		for _, conn := range []Connectivity{FourConnected, EightConnected} {
+			count, err := CountIslandsStream(NewDenseRows(grid), conn)
+			assert.NoError(t, err)
+			if !assert.Equal(t, CountIslandsWith(grid, conn), count, fmt.Sprintf("trial %d, %d-connected: %v", trial, conn, grid)) {
+				return
+			}
+		}
+	}
+}
+
+type failingRows struct{}
+
+func (failingRows) ReadRow() ([]int, error) {
+	return nil, errors.New("disk on fire")
+}
+
+func TestCountIslandsStreamPropagatesErrors(t *testing.T) {
+	_, err := CountIslandsStream(failingRows{}, FourConnected)
+	assert.EqualError(t, err, "disk on fire")
+
+	count, err := CountIslandsStream(NewDenseRows(nil), FourConnected)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+// wideRows generates a tall striped grid without ever holding it all.
+type wideRows struct {
+	rows, cols, next int
+	row              []int
+}
+
+func (w *wideRows) ReadRow() ([]int, error) {
+	if w.next == w.rows {
+		return nil, io.EOF
+	}
+	for j := range w.row {
+		w.row[j] = (j / 2) % 2
+	}
+	w.next++
+	return w.row, nil
+}
+
+func TestCountIslandsStreamLargeGrid(t *testing.T) {
+	rows := &wideRows{rows: 5000, cols: 1000, row: make([]int, 1000)}
+	count, err := CountIslandsStream(rows, FourConnected)
+	assert.NoError(t, err)
+	assert.Equal(t, 250, count, "every vertical stripe should be one island")
+}
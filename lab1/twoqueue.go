@@ -0,0 +1,97 @@
+package main
+
+import (
+	"container/list"
+)
+
+// twoQueueItem is a resident key and the queue currently holding it.
+type twoQueueItem[K comparable] struct {
+	key  K
+	main bool
+}
+
+// twoQueuePolicy implements the full 2Q algorithm (Johnson & Shasha):
+// new keys enter a FIFO probation queue (a1in) and are only promoted
+// to the main LRU queue (am) if they are admitted again while their key
+// is still remembered in the ghost queue (a1out). One-off scans
+// therefore churn a1in and leave am intact.
+type twoQueuePolicy[K comparable] struct {
+	maxIn  int
+	maxOut int
+	a1in   *list.List
+	am     *list.List
+	a1out  *list.List
+	hash   map[K]*list.Element
+	ghosts map[K]*list.Element
+}
+
+// NewTwoQueuePolicy returns a 2Q eviction policy tuned for a cache of
+// the given capacity, using the paper's recommended 25% probation
+// queue and a ghost history of half the capacity.
+func NewTwoQueuePolicy[K comparable](capacity int) Policy[K] {
+	return &twoQueuePolicy[K]{
+		maxIn:  max(1, capacity/4),
+		maxOut: max(1, capacity/2),
+		a1in:   list.New(),
+		am:     list.New(),
+		a1out:  list.New(),
+		hash:   make(map[K]*list.Element),
+		ghosts: make(map[K]*list.Element),
+	}
+}
+
+func (p *twoQueuePolicy[K]) Admit(key K) {
+	if ghost, ok := p.ghosts[key]; ok {
+		// the key was seen recently, it goes straight to the main queue
+		p.a1out.Remove(ghost)
+		delete(p.ghosts, key)
+		p.hash[key] = p.am.PushFront(&twoQueueItem[K]{key: key, main: true})
+	} else {
+		p.hash[key] = p.a1in.PushFront(&twoQueueItem[K]{key: key})
+	}
+
+	// trim the ghost history only once the incoming key has been checked
+	for p.a1out.Len() > p.maxOut {
+		delete(p.ghosts, p.a1out.Remove(p.a1out.Back()).(K))
+	}
+}
+
+func (p *twoQueuePolicy[K]) Access(key K) {
+	// hits in a1in are ignored so correlated references do not promote
+	if element, ok := p.hash[key]; ok && element.Value.(*twoQueueItem[K]).main {
+		p.am.MoveToFront(element)
+	}
+}
+
+func (p *twoQueuePolicy[K]) Remove(key K) {
+	if element, ok := p.hash[key]; ok {
+		p.unlink(element)
+	}
+}
+
+func (p *twoQueuePolicy[K]) Victim(incoming K) (K, bool) {
+	if p.a1in.Len() > p.maxIn || (p.am.Len() == 0 && p.a1in.Len() > 0) {
+		// evict from probation and remember the key as a ghost
+		key := p.unlink(p.a1in.Back())
+		p.ghosts[key] = p.a1out.PushFront(key)
+		return key, true
+	}
+
+	if p.am.Len() > 0 {
+		return p.unlink(p.am.Back()), true
+	}
+
+	var zero K
+	return zero, false
+}
+
+func (p *twoQueuePolicy[K]) unlink(element *list.Element) K {
+	item := element.Value.(*twoQueueItem[K])
+	if item.main {
+		p.am.Remove(element)
+	} else {
+		p.a1in.Remove(element)
+	}
+	delete(p.hash, item.key)
+	return item.key
+}
@@ -0,0 +1,52 @@
+package main
+
+// unionFind is a disjoint set forest over the integers 0..n-1 using
+// union by size and path halving.
+type unionFind struct {
+	parent []int
+	size   []int
+}
+
+func newUnionFind(n int) *unionFind {
+	u := &unionFind{
+		parent: make([]int, n),
+		size:   make([]int, n),
+	}
+	for i := range u.parent {
+		u.parent[i] = i
+		u.size[i] = 1
+	}
+	return u
+}
+
+// add appends a new singleton set and returns its element.
+func (u *unionFind) add() int {
+	u.parent = append(u.parent, len(u.parent))
+	u.size = append(u.size, 1)
+	return len(u.parent) - 1
+}
+
+func (u *unionFind) find(x int) int {
+	for u.parent[x] != x {
+		u.parent[x] = u.parent[u.parent[x]]
+		x = u.parent[x]
+	}
+	return x
+}
+
+// union merges the sets holding a and b and reports whether they were
+// separate before.
+func (u *unionFind) union(a int, b int) bool {
+	a, b = u.find(a), u.find(b)
+	if a == b {
+		return false
+	}
+
+	// hang the smaller tree under the larger one
+	if u.size[a] < u.size[b] {
+		a, b = b, a
+	}
+	u.parent[b] = a
+	u.size[a] += u.size[b]
+	return true
+}
@@ -0,0 +1,2 @@
+#!/bin/bash
+curl -X DELETE -v http://localhost:$1/$2
\ No newline at end of file
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/SamSirsikar/DistributedSystems/partition"
+)
+
+// NodeLoad is the number of keys a node owns against the number its
+// weight entitles it to.
+type NodeLoad struct {
+	Node     string
+	Weight   int
+	Keys     int
+	Expected float64
+}
+
+// DistributionReport describes how evenly a ring spreads a set of keys.
+// StdDev is the standard deviation of the nodes' key counts from their
+// expected counts, and RelativeStdDev the same as a fraction of the mean
+// expected count. MaxLoad is the largest ratio of keys to expected keys.
+type DistributionReport struct {
+	Keys           int
+	Nodes          []NodeLoad
+	StdDev         float64
+	RelativeStdDev float64
+	MaxLoad        float64
+}
+
+// Distribution places the keys on the ring and reports how many every
+// node owns.
+func Distribution(ring *partition.Ring, keys []string) DistributionReport {
+	counts := make(map[string]int)
+	for _, key := range keys {
+		// a ring with nodes always finds one
+		node, _ := ring.Get(key)
+		counts[node]++
+	}
+
+	totalWeight := 0
+	for _, node := range ring.Members() {
+		totalWeight += ring.Weight(node)
+	}
+
+	report := DistributionReport{Keys: len(keys)}
+	sumSquares := 0.0
+	for _, node := range ring.Members() {
+		load := NodeLoad{
+			Node:     node,
+			Weight:   ring.Weight(node),
+			Keys:     counts[node],
+			Expected: float64(len(keys)) * float64(ring.Weight(node)) / float64(totalWeight),
+		}
+		report.Nodes = append(report.Nodes, load)
+
+		deviation := float64(load.Keys) - load.Expected
+		sumSquares += deviation * deviation
+		if load.Expected > 0 {
+			report.MaxLoad = math.Max(report.MaxLoad, float64(load.Keys)/load.Expected)
+		}
+	}
+
+	if len(report.Nodes) > 0 && len(keys) > 0 {
+		report.StdDev = math.Sqrt(sumSquares / float64(len(report.Nodes)))
+		report.RelativeStdDev = report.StdDev / (float64(len(keys)) / float64(len(report.Nodes)))
+	}
+	return report
+}
+
+// String formats the report as a table with one row per node.
+func (r DistributionReport) String() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "node\tweight\tkeys\texpected\tshare")
+	for _, load := range r.Nodes {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.0f\t%.2f%%\n",
+			load.Node, load.Weight, load.Keys, load.Expected, 100*float64(load.Keys)/float64(max(1, r.Keys)))
+	}
+	w.Flush()
+
+	fmt.Fprintf(&b, "keys: %d, std dev: %.1f (%.2f%%), max load: %.3f\n",
+		r.Keys, r.StdDev, 100*r.RelativeStdDev, r.MaxLoad)
+	return b.String()
+}
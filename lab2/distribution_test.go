@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/SamSirsikar/DistributedSystems/partition"
+	"github.com/stretchr/testify/assert"
+)
+
+// testKeys returns n keys to place on a ring.
+func testKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprint(i)
+	}
+	return keys
+}
+
+func newTestRing(virtualNodes int, nodes int) *partition.Ring {
+	ring := partition.NewRingWithVirtualNodes(virtualNodes)
+	for i := 0; i < nodes; i++ {
+		ring.Add(nodeURL(3001 + i))
+	}
+	return ring
+}
+
+func TestDistributionBalance(t *testing.T) {
+	assert := assert.New(t)
+	keys := testKeys(100000)
+
+	// a single point per node leaves some nodes with a tiny arc
+	single := Distribution(newTestRing(1, 5), keys)
+	assert.Greater(single.RelativeStdDev, 0.5)
+
+	report := Distribution(newTestRing(partition.DefaultVirtualNodes, 5), keys)
+	t.Log("\n" + report.String())
+	assert.Equal(100000, report.Keys)
+	assert.Len(report.Nodes, 5)
+	assert.Less(report.RelativeStdDev, 0.15)
+	assert.Less(report.MaxLoad, 1.2)
+
+	total := 0
+	for _, load := range report.Nodes {
+		total += load.Keys
+		assert.InDelta(20000, load.Keys, 20000*0.2)
+	}
+	assert.Equal(100000, total)
+
+	// more points per node balance the keys further
+	fine := Distribution(newTestRing(4*partition.DefaultVirtualNodes, 5), keys)
+	assert.Less(fine.RelativeStdDev, report.RelativeStdDev)
+}
+
+func TestDistributionWeights(t *testing.T) {
+	assert := assert.New(t)
+
+	ring := partition.NewRing()
+	ring.AddWeighted(nodeURL(3001), 3)
+	ring.AddWeighted(nodeURL(3002), 2)
+	ring.Add(nodeURL(3003))
+	ring.Add(nodeURL(3004))
+
+	// a node's share of the keys follows its share of the weight
+	report := Distribution(ring, testKeys(100000))
+	t.Log("\n" + report.String())
+	expected := map[string]float64{
+		nodeURL(3001): 100000 * 3 / 7.0,
+		nodeURL(3002): 100000 * 2 / 7.0,
+		nodeURL(3003): 100000 * 1 / 7.0,
+		nodeURL(3004): 100000 * 1 / 7.0,
+	}
+	for _, load := range report.Nodes {
+		assert.InDelta(expected[load.Node], load.Expected, 0.001)
+		assert.InEpsilon(load.Expected, float64(load.Keys), 0.2)
+	}
+}
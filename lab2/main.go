@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/SamSirsikar/DistributedSystems/kvclient"
+	"github.com/SamSirsikar/DistributedSystems/partition"
+)
+
+const usage = `usage:
+  go run . [-partitioner ring|hrw|jump|maglev] server 3001-3005 [data-dir|memory] [replicas]
+  go run . [-partitioner ring|hrw|jump|maglev] client 3001-3005 "1->A,2->B,3->C,4->D,5->E" [replicas]
+  go run . [-partitioner ring|hrw|jump|maglev] get 3001-3005 1 [replicas]
+  go run . [-partitioner ring|hrw|jump|maglev] scan 3001-3005 [prefix] [start] [end]
+  go run . report 3001-3005 [virtual-nodes] [keys]
+  go run . rebalance 3001-3005 3001-3006 [replicas] [dry-run|prune]
+  go run . compare 3001-3005 [keys] [table|csv]`
+
+func main() {
+	// the strategy that places the keys on the servers; the servers and
+	// the clients must use the same one
+	strategy := flag.String("partitioner", "ring", "ring, hrw, jump or maglev")
+	flag.Usage = func() { fmt.Println(usage) }
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) < 2 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+
+	// get the start and end ports
+	startEndPort := strings.Split(args[1], "-")
+	startPort, _ := strconv.Atoi(startEndPort[0])
+	endPort, _ := strconv.Atoi(startEndPort[len(startEndPort)-1])
+
+	// create a consistent hash ring
+	ch := partition.NewRing()
+	if args[0] == "report" && len(args) > 2 {
+		virtualNodes, _ := strconv.Atoi(args[2])
+		ch = partition.NewRingWithVirtualNodes(virtualNodes)
+	}
+
+	nodes := make([]string, 0)
+	for i := startPort; i <= endPort; i++ {
+		// add each server to the consistent hash
+		ch.Add(nodeURL(i))
+		nodes = append(nodes, nodeURL(i))
+	}
+
+	// and to a client that places the keys as the servers do
+	client, err := kvclient.New(*strategy, nodes)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "server":
+		// every node keeps its log and snapshot in data-dir/{port}, or
+		// its data in memory only when data-dir is "memory"
+		dataDir := "data"
+		if len(args) > 2 {
+			dataDir = args[2]
+		}
+		if dataDir == "memory" {
+			dataDir = ""
+		}
+		replicas := 1
+		if len(args) > 3 {
+			var err error
+			replicas, err = strconv.Atoi(args[3])
+			if err != nil || replicas < 1 {
+				fmt.Println("invalid number of replicas:", args[3])
+				os.Exit(1)
+			}
+		}
+
+		// create a ports array to store all the ports
+		ports := make([]int, 0)
+		for i := startPort; i <= endPort; i++ {
+			ports = append(ports, i)
+		}
+
+		// create a http server instance
+		// with the required number of servers
+		server := NewHTTPServer(ports)
+		server.DataDir = dataDir
+		server.Replicas = replicas
+		server.Partitioner = *strategy
+
+		// start all the servers
+		server.Start()
+	case "client":
+		if len(args) < 3 {
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+		replicas := 1
+		if len(args) > 3 {
+			replicas, _ = strconv.Atoi(args[3])
+		}
+
+		client.Replicas = replicas
+
+		keyValuePairs := strings.Split(args[2], ",")
+		for i := 0; i < len(keyValuePairs); i++ {
+			keyValue := strings.Split((keyValuePairs[i]), "->")
+			if len(keyValue) != 2 {
+				fmt.Println("skipping malformed pair:", keyValuePairs[i])
+				continue
+			}
+
+			// now, determine which servers store the key,
+			// the primary being the first one
+			urls, _ := client.Partitioner.GetN(keyValue[0], replicas)
+
+			// now, make a request to the first one that is up using
+			// the key in the path and the value in the body
+			// example: PUT http://localhost:3001/1 with body A
+			// will save the value A at key 1 on server 3001,
+			// which forwards it to the other replicas
+			fmt.Printf("Sending %s to %s\n", keyValuePairs[i], urls)
+			if err := client.Put(keyValue[0], keyValue[1]); err != nil {
+				fmt.Println("Request failed:", err)
+				continue
+			}
+			fmt.Println("Stored", keyValue[0])
+		}
+	case "get":
+		if len(args) < 3 {
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+		replicas := 1
+		if len(args) > 3 {
+			replicas, _ = strconv.Atoi(args[3])
+		}
+
+		// read from the primary, or a replica if the primary is down
+		client.Replicas = replicas
+		val, err := client.Get(args[2])
+		switch {
+		case errors.Is(err, kvclient.ErrNotFound):
+			fmt.Println("Key", args[2], "not found")
+		case err != nil:
+			fmt.Println("Request failed:", err)
+		default:
+			fmt.Println(val)
+		}
+	case "scan":
+		// list the keys of every server at once, optionally only those
+		// with the prefix in [start, end)
+		var filter kvclient.Filter
+		if len(args) > 2 {
+			filter.Prefix = args[2]
+		}
+		if len(args) > 3 {
+			filter.Start = args[3]
+		}
+		if len(args) > 4 {
+			filter.End = args[4]
+		}
+
+		result, err := client.Scan(filter)
+		if err != nil {
+			fmt.Println("Request failed:", err)
+			os.Exit(1)
+		}
+		for _, entry := range result.Entries {
+			fmt.Printf("%s->%s\n", entry.Key, entry.Value)
+		}
+		if len(result.Unreachable) > 0 {
+			// the keys that only these servers hold are missing
+			fmt.Println("Unreachable:", result.Unreachable)
+		}
+	case "report":
+		n := 100000
+		if len(args) > 3 {
+			n, _ = strconv.Atoi(args[3])
+		}
+
+		// show how evenly the ring spreads n keys over the servers
+		keys := make([]string, n)
+		for i := range keys {
+			keys[i] = strconv.Itoa(i)
+		}
+		fmt.Print(Distribution(ch, keys))
+	case "compare":
+		n := 100000
+		if len(args) > 2 {
+			n, _ = strconv.Atoi(args[2])
+		}
+		keys := make([]string, n)
+		for i := range keys {
+			keys[i] = strconv.Itoa(i)
+		}
+
+		// place the keys with every strategy, then add the next port
+		// and remove the first one
+		comparisons := make([]partition.Comparison, 0)
+		for _, name := range partition.Strategies {
+			comparison, err := partition.Compare(name, nodes, nodeURL(endPort+1), keys)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			comparisons = append(comparisons, comparison)
+		}
+
+		if len(args) > 3 && args[3] == "csv" {
+			partition.WriteCSV(os.Stdout, comparisons)
+		} else {
+			partition.WriteTable(os.Stdout, comparisons)
+		}
+	case "rebalance":
+		if len(args) < 3 {
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+		if *strategy != "ring" {
+			// only the ring moves whole ranges of keys
+			fmt.Println("rebalance only supports the ring partitioner")
+			os.Exit(1)
+		}
+		replicas := 1
+		if len(args) > 3 {
+			replicas, _ = strconv.Atoi(args[3])
+		}
+
+		// the ring the servers are started with now
+		newStartEndPort := strings.Split(args[2], "-")
+		newStartPort, _ := strconv.Atoi(newStartEndPort[0])
+		newEndPort, _ := strconv.Atoi(newStartEndPort[len(newStartEndPort)-1])
+		after := partition.NewRing()
+		for i := newStartPort; i <= newEndPort; i++ {
+			after.Add(nodeURL(i))
+		}
+
+		// copy every range whose replicas changed to its new replicas,
+		// or only count the keys in a dry run
+		rebalancer := NewRebalancer()
+		rebalancer.Out = os.Stdout
+		if len(args) > 4 {
+			rebalancer.DryRun = args[4] == "dry-run"
+			rebalancer.Prune = args[4] == "prune"
+		}
+
+		stats, err := rebalancer.Run(PlanMoves(ch, after, replicas))
+		fmt.Println(stats)
+		if err != nil {
+			fmt.Println("Rebalance failed:", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+}
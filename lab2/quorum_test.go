@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SamSirsikar/DistributedSystems/partition"
+	"github.com/stretchr/testify/assert"
+)
+
+// startHTTPServer runs a replicated HTTPServer on free ports in-process
+// and waits until every node answers.
+func startHTTPServer(t *testing.T, nodes int, replicas int) *HTTPServer {
+	ports := make([]int, 0)
+	for i := 0; i < nodes; i++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ports = append(ports, listener.Addr().(*net.TCPAddr).Port)
+		listener.Close()
+	}
+
+	server := NewHTTPServer(ports)
+	server.Replicas = replicas
+
+	done := make(chan bool)
+	go func() {
+		server.Start()
+		done <- true
+	}()
+
+	for _, port := range ports {
+		assert.Eventually(t, func() bool {
+			response, err := http.Get(nodeURL(port) + "/")
+			if err != nil {
+				return false
+			}
+			response.Body.Close()
+			return true
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	t.Cleanup(func() {
+		for _, port := range ports {
+			server.Stop(port)
+		}
+		<-done
+	})
+	return server
+}
+
+// replicaPorts returns the ports of the replicas of key, primary first.
+func replicaPorts(server *HTTPServer, key string) []int {
+	ring := partition.NewRing()
+	ports := make(map[string]int)
+	for _, port := range server.Ports {
+		ring.Add(nodeURL(port))
+		ports[nodeURL(port)] = port
+	}
+
+	replicas := make([]int, 0)
+	nodes, _ := ring.GetN(key, server.Replicas)
+	for _, node := range nodes {
+		replicas = append(replicas, ports[node])
+	}
+	return replicas
+}
+
+// call sends a request and returns the response with its body read.
+func call(t *testing.T, method string, url string, body string) (*http.Response, string) {
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	assert.NoError(t, err)
+	response, err := http.DefaultClient.Do(request)
+	if !assert.NoError(t, err) {
+		return &http.Response{}, ""
+	}
+	defer response.Body.Close()
+	contents, _ := io.ReadAll(response.Body)
+	return response, string(contents)
+}
+
+// inject writes an entry straight into one replica, as if a write had
+// only reached that one.
+func inject(t *testing.T, port int, key string, entry Entry) {
+	body, _ := json.Marshal(entry)
+	request, _ := http.NewRequest("PUT", nodeURL(port)+"/"+key, bytes.NewReader(body))
+	request.Header.Set(replicaHeader, "test")
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	assert.Equal(t, 204, response.StatusCode)
+	response.Body.Close()
+}
+
+func TestQuorumWritesAndReadsWithFailedReplica(t *testing.T) {
+	assert := assert.New(t)
+	server := startHTTPServer(t, 5, 3)
+	replicas := replicaPorts(server, "1")
+	primary := nodeURL(replicas[0])
+
+	assert.NoError(server.Stop(replicas[2]))
+
+	// two of the three replicas are up
+	response, body := call(t, "PUT", primary+"/1?w=3", "A")
+	assert.Equal(503, response.StatusCode)
+	assert.Equal("2", response.Header.Get(acksHeader))
+	assert.Equal(`{"error":"only 2 of 3 replicas applied the write"}`, body)
+
+	response, _ = call(t, "PUT", primary+"/1?w=2", "B")
+	assert.Equal(204, response.StatusCode)
+	assert.Equal("2", response.Header.Get(acksHeader))
+
+	// the default quorum is a majority
+	response, _ = call(t, "PUT", primary+"/1", "C")
+	assert.Equal(204, response.StatusCode)
+
+	response, body = call(t, "GET", primary+"/1?r=3", "")
+	assert.Equal(503, response.StatusCode)
+	assert.Equal(`{"error":"only 2 of 3 replicas answered"}`, body)
+
+	response, body = call(t, "GET", primary+"/1?r=2", "")
+	assert.Equal(200, response.StatusCode)
+	assert.Equal(`{"key":"1","value":"C"}`, body)
+	assert.NotEmpty(response.Header.Get(versionHeader))
+
+	// the primary going down as well leaves a single replica
+	assert.NoError(server.Stop(replicas[0]))
+	secondary := nodeURL(replicas[1])
+
+	response, _ = call(t, "GET", secondary+"/1", "")
+	assert.Equal(503, response.StatusCode)
+	response, body = call(t, "GET", secondary+"/1?r=1", "")
+	assert.Equal(200, response.StatusCode)
+	assert.Equal(`{"key":"1","value":"C"}`, body)
+	response, _ = call(t, "PUT", secondary+"/1?w=1", "D")
+	assert.Equal(204, response.StatusCode)
+}
+
+func TestQuorumReadResolvesConflicts(t *testing.T) {
+	assert := assert.New(t)
+	server := startHTTPServer(t, 4, 3)
+	replicas := replicaPorts(server, "k")
+	primary := nodeURL(replicas[0])
+
+	response, _ := call(t, "PUT", primary+"/k?w=3", "old")
+	assert.Equal(204, response.StatusCode)
+
+	// a newer write reached the last replica only
+	newer := Entry{Value: "new", Version: Version{uint64(time.Now().UnixNano()) + uint64(time.Hour), "other"}}
+	inject(t, replicas[2], "k", newer)
+
+	// reading a single replica can return the stale value
+	_, body := call(t, "GET", primary+"/k?r=1", "")
+	assert.Equal(`{"key":"k","value":"old"}`, body)
+
+	// reading all of them returns the newest version
+	response, body = call(t, "GET", primary+"/k?r=3", "")
+	assert.Equal(`{"key":"k","value":"new"}`, body)
+	assert.Equal(newer.Version.String(), response.Header.Get(versionHeader))
+
+	// and repairs the replicas that were behind
+	for _, port := range replicas[:2] {
+		assert.Eventually(func() bool {
+			_, body := call(t, "GET", nodeURL(port)+"/k?r=1", "")
+			return body == `{"key":"k","value":"new"}`
+		}, time.Second, 10*time.Millisecond)
+	}
+}
+
+func TestQuorumAtomicOperationsSeeMissedWrites(t *testing.T) {
+	assert := assert.New(t)
+	server := startHTTPServer(t, 5, 3)
+	replicas := replicaPorts(server, "n")
+
+	response, _ := call(t, "PUT", nodeURL(replicas[0])+"/n?w=3", "1")
+	assert.Equal(204, response.StatusCode)
+
+	// a write the primary missed reached the other two replicas
+	missed := Entry{Value: "10", Version: Version{uint64(time.Now().UnixNano()) + uint64(time.Hour), "other"}}
+	inject(t, replicas[1], "n", missed)
+	inject(t, replicas[2], "n", missed)
+
+	// an increment sent to the primary builds on it all the same
+	response, body := call(t, "POST", nodeURL(replicas[0])+"/n/incr/1", "")
+	assert.Equal(200, response.StatusCode)
+	assert.Equal(`{"key":"n","value":11}`, body)
+	_, body = call(t, "GET", nodeURL(replicas[0])+"/n?r=3", "")
+	assert.Equal(`{"key":"n","value":"11"}`, body)
+
+	// concurrent increments through different nodes are all counted
+	done := make(chan bool)
+	for i := 0; i < 20; i++ {
+		go func(port int) {
+			response, _ := call(t, "POST", nodeURL(port)+"/n/incr/1", "")
+			assert.Equal(200, response.StatusCode)
+			done <- true
+		}(server.Ports[i%len(server.Ports)])
+	}
+	for i := 0; i < 20; i++ {
+		<-done
+	}
+	_, body = call(t, "GET", nodeURL(replicas[0])+"/n?r=3", "")
+	assert.Equal(`{"key":"n","value":"31"}`, body)
+
+	// with the primary down, the next replica evaluates them, on a write
+	// that only reached the other one
+	assert.NoError(server.Stop(replicas[0]))
+	missed = Entry{Value: "50", Version: Version{missed.Version.Counter + uint64(time.Hour), "other"}}
+	inject(t, replicas[1], "n", missed)
+
+	response, body = call(t, "POST", nodeURL(replicas[2])+"/n/incr/1", "")
+	assert.Equal(200, response.StatusCode)
+	assert.Equal(`{"key":"n","value":51}`, body)
+
+	response, _ = call(t, "POST", nodeURL(replicas[2])+"/n/cas/51/52", "")
+	assert.Equal(204, response.StatusCode)
+	_, body = call(t, "GET", nodeURL(replicas[2])+"/n?r=2", "")
+	assert.Equal(`{"key":"n","value":"52"}`, body)
+}
+
+func TestQuorumDeleteKeepsTombstone(t *testing.T) {
+	assert := assert.New(t)
+	server := startHTTPServer(t, 3, 3)
+	replicas := replicaPorts(server, "k")
+
+	call(t, "PUT", nodeURL(replicas[0])+"/k?w=3", "A")
+	response, _ := call(t, "DELETE", nodeURL(replicas[1])+"/k?w=3", "")
+	assert.Equal(204, response.StatusCode)
+	assert.Equal("3", response.Header.Get(acksHeader))
+
+	// a write older than the delete arrives late at one replica
+	inject(t, replicas[2], "k", Entry{Value: "late", Version: Version{1, "other"}})
+
+	for _, port := range replicas {
+		response, _ = call(t, "GET", nodeURL(port)+"/k?r=3", "")
+		assert.Equal(404, response.StatusCode)
+	}
+}
+
+func TestQuorumDeleteOfAbsentKey(t *testing.T) {
+	assert := assert.New(t)
+	server := startHTTPServer(t, 3, 2)
+	replicas := replicaPorts(server, "k")
+
+	// a key no replica ever saw
+	response, _ := call(t, "DELETE", nodeURL(replicas[0])+"/k?w=2", "")
+	assert.Equal(204, response.StatusCode)
+	assert.Equal("2", response.Header.Get(acksHeader))
+
+	// a key only the second replica holds is deleted there too, even
+	// when the delete does not wait for it
+	replicas = replicaPorts(server, "j")
+	inject(t, replicas[1], "j", Entry{Value: "A", Version: Version{1, "other"}})
+	response, _ = call(t, "DELETE", nodeURL(replicas[0])+"/j?w=1", "")
+	assert.Equal(204, response.StatusCode)
+	for _, port := range replicas {
+		assert.Eventually(func() bool {
+			response, _ := call(t, "GET", nodeURL(port)+"/j?r=1", "")
+			return response.StatusCode == 404
+		}, time.Second, 10*time.Millisecond)
+	}
+}
+
+func TestQuorumFailureIsNotRetriedThroughNonReplica(t *testing.T) {
+	assert := assert.New(t)
+	server := startHTTPServer(t, 5, 3)
+	replicas := replicaPorts(server, "c")
+
+	other := 0
+	for _, port := range server.Ports {
+		if port != replicas[0] && port != replicas[1] && port != replicas[2] {
+			other = port
+		}
+	}
+
+	// the first replica applies the increment and fails the quorum, and
+	// the increment must not be applied again on the next replica
+	assert.NoError(server.Stop(replicas[2]))
+	response, body := call(t, "POST", nodeURL(other)+"/c/incr/5?w=3", "")
+	assert.Equal(503, response.StatusCode)
+	assert.Equal(`{"error":"only 2 of 3 replicas applied the write"}`, body)
+
+	for _, port := range replicas[:2] {
+		_, body := call(t, "GET", nodeURL(port)+"/c?r=1", "")
+		assert.Equal(`{"key":"c","value":"5"}`, body)
+	}
+}
+
+func TestQuorumRejectsInvalidQuorums(t *testing.T) {
+	assert := assert.New(t)
+	server := startHTTPServer(t, 3, 2)
+	primary := nodeURL(replicaPorts(server, "k")[0])
+
+	for _, query := range []string{"r=0", "r=3", "r=x"} {
+		response, body := call(t, "GET", primary+"/k?"+query, "")
+		assert.Equal(400, response.StatusCode)
+		assert.Equal(`{"error":"r must be between 1 and 2"}`, body)
+	}
+
+	response, body := call(t, "PUT", primary+"/k?w=5", "A")
+	assert.Equal(400, response.StatusCode)
+	assert.Equal(`{"error":"w must be between 1 and 2"}`, body)
+}
+
+func TestQuorumThroughNonReplica(t *testing.T) {
+	assert := assert.New(t)
+	server := startHTTPServer(t, 5, 3)
+	replicas := replicaPorts(server, "k")
+
+	other := 0
+	for _, port := range server.Ports {
+		if port != replicas[0] && port != replicas[1] && port != replicas[2] {
+			other = port
+		}
+	}
+
+	// the quorum parameters travel with the proxied request
+	response, _ := call(t, "PUT", nodeURL(other)+"/k?w=3", "A")
+	assert.Equal(204, response.StatusCode)
+	assert.Equal("3", response.Header.Get(acksHeader))
+
+	assert.NoError(server.Stop(replicas[0]))
+	response, body := call(t, "GET", nodeURL(other)+"/k?r=2", "")
+	assert.Equal(200, response.StatusCode)
+	assert.Equal(`{"key":"k","value":"A"}`, body)
+	assert.NotEmpty(response.Header.Get(versionHeader))
+}
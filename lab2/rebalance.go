@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/SamSirsikar/DistributedSystems/partition"
+)
+
+// Move is a range of keys whose replicas change between two rings. The
+// keys are copied from the first reachable node in From to every node in
+// To, and the nodes in Drop no longer store them.
+type Move struct {
+	Range KeyRange
+	From  []string
+	To    []string
+	Drop  []string
+}
+
+func (m Move) String() string {
+	return fmt.Sprintf("%s from %v to %v, drop on %v", m.Range, m.From, m.To, m.Drop)
+}
+
+// PlanMoves compares the replicas of every arc of the ring before and
+// after a membership change and returns the arcs whose keys must move,
+// with adjacent arcs that move the same way joined into one.
+func PlanMoves(before *partition.Ring, after *partition.Ring, replicas int) []Move {
+	if len(before.Points()) == 0 || len(after.Points()) == 0 {
+		return nil
+	}
+
+	// every point of either ring bounds an arc that has the same
+	// replicas on both rings from one end to the other
+	points := append(before.Points(), after.Points()...)
+	slices.Sort(points)
+	points = slices.Compact(points)
+
+	moves := make([]Move, 0)
+	for i, end := range points {
+		// the first arc wraps around from the last point
+		start := points[(i+len(points)-1)%len(points)]
+
+		owners := before.GetNAt(end, replicas)
+		newOwners := after.GetNAt(end, replicas)
+
+		move := Move{Range: KeyRange{Start: start, End: end}, From: owners}
+		for _, node := range newOwners {
+			if !slices.Contains(owners, node) {
+				move.To = append(move.To, node)
+			}
+		}
+		for _, node := range owners {
+			if !slices.Contains(newOwners, node) {
+				move.Drop = append(move.Drop, node)
+			}
+		}
+		if len(move.To) == 0 && len(move.Drop) == 0 {
+			continue
+		}
+
+		// extend the previous arc if the keys go the same way
+		if n := len(moves); n > 0 && moves[n-1].Range.End == start &&
+			slices.Equal(moves[n-1].From, move.From) &&
+			slices.Equal(moves[n-1].To, move.To) &&
+			slices.Equal(moves[n-1].Drop, move.Drop) {
+			moves[n-1].Range.End = end
+			continue
+		}
+		moves = append(moves, move)
+	}
+
+	return moves
+}
+
+// RebalanceStats counts what a rebalance did, or would do in a dry run.
+type RebalanceStats struct {
+	Moves   int
+	Copied  int
+	Applied int
+	Dropped int
+}
+
+func (s RebalanceStats) String() string {
+	return fmt.Sprintf("moves: %d, keys copied: %d, applied: %d, dropped: %d",
+		s.Moves, s.Copied, s.Applied, s.Dropped)
+}
+
+// Rebalancer streams the keys of planned moves between running servers.
+// A dry run only counts the keys each move would copy. With Prune the
+// nodes that no longer store a range drop it once every copy succeeded.
+type Rebalancer struct {
+	Client *http.Client
+	DryRun bool
+	Prune  bool
+	// Out receives a line per move, nil discards them
+	Out io.Writer
+}
+
+func NewRebalancer() *Rebalancer {
+	return &Rebalancer{
+		Client: &http.Client{Timeout: time.Minute},
+		Out:    io.Discard,
+	}
+}
+
+// Run carries out the moves in order. A move that fails is reported and
+// skipped, so its keys stay where they were, and the errors of all moves
+// are returned together.
+func (b *Rebalancer) Run(moves []Move) (RebalanceStats, error) {
+	stats := RebalanceStats{}
+	errs := make([]error, 0)
+
+	for _, move := range moves {
+		stats.Moves++
+
+		if b.DryRun {
+			keys, err := b.count(move)
+			if err != nil {
+				errs = append(errs, err)
+				b.printf("%s: %s\n", move, err)
+				continue
+			}
+			stats.Copied += keys
+			b.printf("%s: %d keys\n", move, keys)
+			continue
+		}
+
+		failed := false
+		for _, node := range move.To {
+			received, applied, err := b.copy(move, node)
+			if err != nil {
+				failed = true
+				errs = append(errs, err)
+				b.printf("%s: %s\n", move, err)
+				continue
+			}
+			stats.Copied += received
+			stats.Applied += applied
+			b.printf("%s: %d keys to %s\n", move, received, node)
+		}
+
+		// keep the old copies of a range that did not fully arrive
+		if !b.Prune || failed {
+			continue
+		}
+		for _, node := range move.Drop {
+			dropped, err := b.drop(move, node)
+			if err != nil {
+				errs = append(errs, err)
+				b.printf("%s: %s\n", move, err)
+				continue
+			}
+			stats.Dropped += dropped
+			b.printf("%s: %d keys dropped on %s\n", move, dropped, node)
+		}
+	}
+
+	return stats, errors.Join(errs...)
+}
+
+func (b *Rebalancer) printf(format string, args ...interface{}) {
+	if b.Out != nil {
+		fmt.Fprintf(b.Out, format, args...)
+	}
+}
+
+// transfer sends a transfer request for the range of a move to a node.
+func (b *Rebalancer) transfer(method string, node string, move Move, body io.Reader) (*http.Response, error) {
+	request, err := http.NewRequest(method, node+"/?"+move.Range.query(), body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set(replicaHeader, "rebalancer")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/x-ndjson")
+	}
+
+	response, err := b.Client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != 200 {
+		response.Body.Close()
+		return nil, fmt.Errorf("%s: status %d", node, response.StatusCode)
+	}
+	return response, nil
+}
+
+// export opens the stream of the range on the first source that is up.
+func (b *Rebalancer) export(move Move, sources []string) (*http.Response, []string, error) {
+	errs := make([]error, 0)
+	for i, source := range sources {
+		response, err := b.transfer("GET", source, move, nil)
+		if err == nil {
+			return response, sources[i+1:], nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, nil, fmt.Errorf("no source of %s is reachable: %w", move.Range, errors.Join(errs...))
+}
+
+// copy streams the range from a source straight into the destination,
+// without holding the keys in memory, and returns how many keys the
+// destination received and how many were newer than its own. A source
+// failing in the middle of the stream is retried on the next one, which
+// is safe because the destination keeps the newer version of every key.
+func (b *Rebalancer) copy(move Move, destination string) (int, int, error) {
+	sources := move.From
+	for {
+		export, rest, err := b.export(move, sources)
+		if err != nil {
+			return 0, 0, err
+		}
+		sources = rest
+
+		response, err := b.transfer("POST", destination, move, export.Body)
+		export.Body.Close()
+		if err != nil {
+			if len(sources) > 0 {
+				continue
+			}
+			return 0, 0, err
+		}
+
+		var result struct {
+			Received int `json:"received"`
+			Applied  int `json:"applied"`
+		}
+		err = json.NewDecoder(response.Body).Decode(&result)
+		response.Body.Close()
+		return result.Received, result.Applied, err
+	}
+}
+
+// count returns the number of keys a move would copy.
+func (b *Rebalancer) count(move Move) (int, error) {
+	export, _, err := b.export(move, move.From)
+	if err != nil {
+		return 0, err
+	}
+	defer export.Body.Close()
+
+	keys := 0
+	decoder := json.NewDecoder(export.Body)
+	for {
+		var entry transferEntry
+		err := decoder.Decode(&entry)
+		if err == io.EOF {
+			return keys, nil
+		}
+		if err != nil {
+			return keys, err
+		}
+		keys++
+	}
+}
+
+// drop removes the range of a move from a node that no longer stores it.
+func (b *Rebalancer) drop(move Move, node string) (int, error) {
+	response, err := b.transfer("DELETE", node, move, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	var result struct {
+		Dropped int `json:"dropped"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&result)
+	return result.Dropped, err
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/SamSirsikar/DistributedSystems/partition"
+	"github.com/stretchr/testify/assert"
+)
+
+// movesOf returns the moves whose range holds the key.
+func movesOf(moves []Move, key string) []Move {
+	found := make([]Move, 0)
+	for _, move := range moves {
+		if move.Range.Contains(partition.RingHash(key)) {
+			found = append(found, move)
+		}
+	}
+	return found
+}
+
+// owner returns the node of the key on a ring with nodes.
+func owner(ring *partition.Ring, key string) string {
+	node, _ := ring.Get(key)
+	return node
+}
+
+func TestKeyRangeContains(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(KeyRange{10, 20}.Contains(20))
+	assert.False(KeyRange{10, 20}.Contains(10))
+	assert.False(KeyRange{10, 20}.Contains(21))
+
+	// wrapping around the end of the ring
+	assert.True(KeyRange{20, 10}.Contains(1<<32 - 1))
+	assert.True(KeyRange{20, 10}.Contains(0))
+	assert.False(KeyRange{20, 10}.Contains(15))
+
+	// the whole ring
+	assert.True(KeyRange{}.Contains(0))
+	assert.True(KeyRange{}.Contains(12345))
+}
+
+func TestPlanMoves(t *testing.T) {
+	for _, replicas := range []int{1, 3} {
+		before := newTestRing(partition.DefaultVirtualNodes, 5)
+		added := newTestRing(partition.DefaultVirtualNodes, 6)
+		removed := newTestRing(partition.DefaultVirtualNodes, 5)
+		removed.Remove(nodeURL(3002))
+
+		for _, after := range []*partition.Ring{added, removed} {
+			moves := PlanMoves(before, after, replicas)
+			assert.NotEmpty(t, moves)
+
+			for _, key := range testKeys(10000) {
+				owners, _ := before.GetN(key, replicas)
+				newOwners, _ := after.GetN(key, replicas)
+				found := movesOf(moves, key)
+
+				// a key moves once, and only if its replicas changed
+				if slices.Equal(owners, newOwners) {
+					assert.Empty(t, found, key)
+					continue
+				}
+				if !assert.Len(t, found, 1, key) {
+					continue
+				}
+				assert.Equal(t, owners, found[0].From)
+				for _, node := range newOwners {
+					assert.Equal(t, !slices.Contains(owners, node), slices.Contains(found[0].To, node))
+				}
+				for _, node := range owners {
+					assert.Equal(t, !slices.Contains(newOwners, node), slices.Contains(found[0].Drop, node))
+				}
+			}
+		}
+	}
+}
+
+func TestPlanMovesUnchangedRing(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(PlanMoves(newTestRing(16, 3), newTestRing(16, 3), 2))
+	assert.Empty(PlanMoves(partition.NewRing(), newTestRing(16, 3), 2))
+}
+
+func TestRebalancerMovesKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	// four standalone nodes, of which the ring only knew three so far
+	stores := make(map[string]*DataStore)
+	before := partition.NewRing()
+	after := partition.NewRing()
+	for i := 0; i < 4; i++ {
+		store := NewDataStore()
+		server := httptest.NewServer(NewStoreHandler(store))
+		t.Cleanup(server.Close)
+
+		stores[server.URL] = store
+		after.Add(server.URL)
+		if i < 3 {
+			before.Add(server.URL)
+		}
+	}
+
+	keys := testKeys(500)
+	for _, key := range keys {
+		assert.NoError(stores[owner(before, key)].Set(key, "value of "+key))
+	}
+	// a deleted key moves as its tombstone
+	assert.NoError(stores[owner(before, "0")].UnSet("0"))
+
+	moved := 0
+	for _, key := range keys {
+		if owner(before, key) != owner(after, key) {
+			moved++
+		}
+	}
+	assert.NotZero(moved)
+
+	moves := PlanMoves(before, after, 1)
+
+	// a dry run counts the keys but leaves them where they are
+	rebalancer := NewRebalancer()
+	rebalancer.DryRun = true
+	stats, err := rebalancer.Run(moves)
+	assert.NoError(err)
+	assert.Equal(len(moves), stats.Moves)
+	assert.Equal(moved, stats.Copied)
+	for _, key := range keys[1:] {
+		_, err := stores[owner(before, key)].Get(key)
+		assert.NoError(err)
+	}
+
+	rebalancer = NewRebalancer()
+	rebalancer.Prune = true
+	stats, err = rebalancer.Run(moves)
+	assert.NoError(err)
+	assert.Equal(moved, stats.Copied)
+	assert.Equal(moved, stats.Applied)
+	assert.Equal(moved, stats.Dropped)
+
+	// every key is now on the node the new ring sends it to, and only there
+	for _, key := range keys[1:] {
+		val, err := stores[owner(after, key)].Get(key)
+		assert.NoError(err)
+		assert.Equal("value of "+key, val)
+
+		if owner(before, key) != owner(after, key) {
+			_, found := stores[owner(before, key)].Lookup(key)
+			assert.False(found)
+		}
+	}
+	entry, found := stores[owner(after, "0")].Lookup("0")
+	assert.True(found)
+	assert.True(entry.Deleted)
+
+	// moving again is harmless: the nodes already hold every key
+	stats, err = rebalancer.Run(PlanMoves(before, after, 1))
+	assert.NoError(err)
+	assert.Zero(stats.Copied)
+}
+
+func TestTransferDropNeedsRange(t *testing.T) {
+	assert := assert.New(t)
+	store := NewDataStore()
+	handler := NewStoreHandler(store)
+	assert.NoError(store.Set("1", "A"))
+
+	drop := func(path string) (int, string) {
+		request := httptest.NewRequest("DELETE", path, nil)
+		request.Header.Set(replicaHeader, "test")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Code, recorder.Body.String()
+	}
+
+	status, body := drop("/")
+	assert.Equal(400, status)
+	assert.Equal(`{"error":"from and to are required to drop a range"}`, body)
+	status, _ = drop("/?from=5")
+	assert.Equal(400, status)
+	_, err := store.Get("1")
+	assert.NoError(err)
+
+	// an explicit whole ring still drops everything
+	status, body = drop("/?from=0&to=0")
+	assert.Equal(200, status)
+	assert.Equal(`{"dropped":1}`, body)
+}
+
+func TestRebalancerReportsUnreachableSource(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(NewStoreHandler(NewDataStore()))
+	defer server.Close()
+
+	move := Move{Range: KeyRange{}, From: []string{"http://127.0.0.1:1"}, To: []string{server.URL}}
+	stats, err := NewRebalancer().Run([]Move{move})
+	assert.Error(err)
+	assert.Zero(stats.Copied)
+}
@@ -0,0 +1,531 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SamSirsikar/DistributedSystems/partition"
+)
+
+const (
+	// replicaHeader marks a request between replicas: a coordinator
+	// reading or writing the entry of a key on a single replica.
+	replicaHeader = "X-Replica"
+	// acksHeader tells the client how many replicas applied a write.
+	acksHeader = "X-Replica-Acks"
+	// versionHeader tells the client the version of the value it read.
+	versionHeader = "X-Version"
+)
+
+// Cluster is the view a node has of every node in the cluster. Each key
+// is stored on Replicas nodes: the ones the partitioner picks for it,
+// the first of which is the primary. A read waits for ReadQuorum replicas to answer
+// and a write for WriteQuorum replicas to apply it; a request can pick
+// its own with the r and w query parameters.
+type Cluster struct {
+	Self        string
+	Nodes       []string
+	Partitioner partition.Partitioner
+	Replicas    int
+	ReadQuorum  int
+	WriteQuorum int
+	client      *http.Client
+}
+
+// NewCluster returns the view of the node self among nodes, which are
+// the base urls of all the nodes including self. Both quorums start as a
+// majority of the replicas, so that every read overlaps the last write.
+// Keys are placed on the consistent hash ring.
+func NewCluster(self string, nodes []string, replicas int) *Cluster {
+	ring := partition.NewRing()
+	for _, node := range nodes {
+		ring.Add(node)
+	}
+
+	replicas = max(1, replicas)
+	return &Cluster{
+		Self:        self,
+		Nodes:       nodes,
+		Partitioner: ring,
+		Replicas:    replicas,
+		ReadQuorum:  replicas/2 + 1,
+		WriteQuorum: replicas/2 + 1,
+		client:      &http.Client{Timeout: 2 * time.Second},
+	}
+}
+
+// ReplicasFor returns the nodes that store key, primary first.
+func (c *Cluster) ReplicasFor(key string) []string {
+	// self is always one of the nodes, so this cannot fail
+	replicas, _ := c.Partitioner.GetN(key, c.Replicas)
+	return replicas
+}
+
+// newPartitioner returns a partitioner of the named strategy over the
+// nodes, see partition.Strategies.
+func newPartitioner(strategy string, nodes []string) (partition.Partitioner, error) {
+	p, err := partition.New(strategy)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		p.Add(node)
+	}
+	return p, nil
+}
+
+// quorum returns the quorum named by the query parameter of the request,
+// or def when the request does not set it.
+func quorum(r *http.Request, name string, def int, replicas int) (int, error) {
+	param := r.URL.Query().Get(name)
+	if param == "" {
+		return min(def, replicas), nil
+	}
+
+	n, err := strconv.Atoi(param)
+	if err != nil || n < 1 || n > replicas {
+		return 0, fmt.Errorf("%s must be between 1 and %d", name, replicas)
+	}
+	return n, nil
+}
+
+// bufferedResponse holds a response so that it can be inspected before
+// it is sent on to the client.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: make(http.Header), status: 200}
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+// send copies the buffered response to w.
+func (b *bufferedResponse) send(w http.ResponseWriter) {
+	for name, values := range b.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes())
+}
+
+// routeKey returns the key a request path refers to, which is always
+// its first segment, or false for the paths that refer to no key.
+func routeKey(path string) (string, bool) {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if segment == "" {
+		return "", false
+	}
+	key, err := url.PathUnescape(segment)
+	return key, err == nil
+}
+
+// NewNodeHandler serves the same API as NewStoreHandler on a node of a
+// replicated cluster. A node that is one of the replicas of a key
+// coordinates the requests for it: it applies a write locally and sends
+// the new entry to the other replicas, and it answers a read with the
+// newest entry among the replicas it heard from. Requests for keys the
+// node does not store are passed on to the first replica that is up.
+// Atomic operations (cas, putifabsent and incr) are evaluated by the
+// first replica that is up, on the newest entry a read quorum holds, so
+// that concurrent operations on a key see each other's outcome.
+// GET /?scope=cluster lists the keys of every node, see Cluster.scatter.
+func NewNodeHandler(dataStore *DataStore, cluster *Cluster) http.Handler {
+	store := NewStoreHandler(dataStore)
+	dataStore.SetNode(cluster.Self)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.EscapedPath()
+		key, ok := routeKey(path)
+		if !ok {
+			if r.Method == "GET" && r.URL.Query().Get("scope") == "cluster" && r.Header.Get(replicaHeader) == "" {
+				cluster.scatter(w, dataStore, parseKeyFilter(r))
+				return
+			}
+			// other listings only concern this node
+			store.ServeHTTP(w, r)
+			return
+		}
+		if r.Header.Get(replicaHeader) != "" {
+			serveReplica(w, r, dataStore, key)
+			return
+		}
+
+		replicas := cluster.ReplicasFor(key)
+		position := -1
+		for i, replica := range replicas {
+			if replica == cluster.Self {
+				position = i
+			}
+		}
+
+		if position < 0 {
+			cluster.proxy(w, r, replicas)
+			return
+		}
+		atomic := r.Method == "POST" && strings.Contains(path[1:], "/")
+		if atomic && position > 0 {
+			// a replica before this one evaluates it, unless none is up
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxValueSize))
+			if err != nil {
+				writeError(w, 413, "value too large")
+				return
+			}
+			if cluster.forward(w, r, body, replicas[:position]) {
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		if r.Method == "GET" && !strings.Contains(path[1:], "/") {
+			// GET /{key}
+			readQuorum, err := quorum(r, "r", cluster.ReadQuorum, len(replicas))
+			if err != nil {
+				writeError(w, 400, err.Error())
+				return
+			}
+			cluster.read(w, dataStore, key, replicas, readQuorum)
+			return
+		}
+
+		writeQuorum, err := quorum(r, "w", cluster.WriteQuorum, len(replicas))
+		if err != nil {
+			writeError(w, 400, err.Error())
+			return
+		}
+
+		if atomic {
+			// catch up with the writes this node missed before evaluating
+			readQuorum, err := quorum(r, "r", cluster.ReadQuorum, len(replicas))
+			if err != nil {
+				writeError(w, 400, err.Error())
+				return
+			}
+			answers, err := cluster.gather(dataStore, key, replicas, readQuorum)
+			if err != nil {
+				writeError(w, 503, err.Error())
+				return
+			}
+			if newest := newestAnswer(answers); newest != nil {
+				dataStore.Merge(key, *newest)
+			}
+		}
+
+		// apply the write here, then send the outcome to the others
+		response := newBufferedResponse()
+		store.ServeHTTP(response, r)
+		if response.status < 200 || response.status >= 300 {
+			response.send(w)
+			return
+		}
+
+		acks := cluster.write(dataStore, key, replicas, writeQuorum)
+		if acks < writeQuorum {
+			w.Header().Set(acksHeader, strconv.Itoa(acks))
+			writeError(w, 503, fmt.Sprintf("only %d of %d replicas applied the write", acks, writeQuorum))
+			return
+		}
+		response.header.Set(acksHeader, strconv.Itoa(acks))
+		response.send(w)
+	})
+}
+
+// serveReplica answers a coordinator: GET returns the entry of the key
+// with its version and PUT merges the entry in the body.
+func serveReplica(w http.ResponseWriter, r *http.Request, dataStore *DataStore, key string) {
+	switch r.Method {
+	case "GET":
+		entry, ok := dataStore.Lookup(key)
+		if !ok {
+			writeError(w, 404, fmt.Sprintf("%s: %q", ErrKeyNotFound, key))
+			return
+		}
+		writeJSON(w, 200, entry)
+	case "PUT":
+		var entry Entry
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxValueSize)).Decode(&entry); err != nil {
+			writeError(w, 400, fmt.Sprintf("invalid entry: %s", err))
+			return
+		}
+
+		// an older entry is acknowledged too: the replica holds the
+		// newer one that replaced it
+		if _, err := dataStore.Merge(key, entry); err != nil {
+			writeError(w, 500, err.Error())
+			return
+		}
+		w.WriteHeader(204)
+	default:
+		methodNotAllowed(w, "GET", "PUT")
+	}
+}
+
+// escape makes a key safe to use as a single url path segment.
+func escape(key string) string {
+	return url.PathEscape(key)
+}
+
+// push sends an entry to a single replica.
+func (c *Cluster) push(replica string, key string, entry Entry) error {
+	body, _ := json.Marshal(entry)
+	request, err := http.NewRequest("PUT", replica+"/"+escape(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(replicaHeader, c.Self)
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	if response.StatusCode != 204 {
+		return fmt.Errorf("%s: status %d", replica, response.StatusCode)
+	}
+	return nil
+}
+
+// fetch reads the entry of a key from a single replica, which reports
+// false if it never saw the key.
+func (c *Cluster) fetch(replica string, key string) (Entry, bool, error) {
+	request, err := http.NewRequest("GET", replica+"/"+escape(key), nil)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	request.Header.Set(replicaHeader, c.Self)
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case 200:
+		var entry Entry
+		err := json.NewDecoder(response.Body).Decode(&entry)
+		return entry, err == nil, err
+	case 404:
+		return Entry{}, false, nil
+	default:
+		return Entry{}, false, fmt.Errorf("%s: status %d", replica, response.StatusCode)
+	}
+}
+
+// write sends the entry the key now has on this node to the other
+// replicas and returns once writeQuorum replicas, counting this one,
+// hold it, or once every replica has answered. The replicas that are
+// slower than the quorum are still updated in the background.
+func (c *Cluster) write(dataStore *DataStore, key string, replicas []string, writeQuorum int) int {
+	entry, _ := dataStore.Lookup(key)
+
+	// buffered so the goroutines never wait for a coordinator that has
+	// already returned
+	results := make(chan error, len(replicas))
+	others := 0
+	for _, replica := range replicas {
+		if replica == c.Self {
+			continue
+		}
+		others++
+
+		go func(replica string) {
+			results <- c.push(replica, key, entry)
+		}(replica)
+	}
+
+	acks := 1
+	for i := 0; i < others && acks < writeQuorum; i++ {
+		if err := <-results; err != nil {
+			fmt.Println("replication failed:", err)
+		} else {
+			acks++
+		}
+	}
+	return acks
+}
+
+// answer is the entry a replica holds for a key.
+type answer struct {
+	replica string
+	entry   Entry
+	found   bool
+	err     error
+}
+
+// read asks the replicas for their entry of the key and answers with the
+// newest once readQuorum replicas, counting this one, have answered. The
+// replicas found holding an older entry are repaired with the newest.
+func (c *Cluster) read(w http.ResponseWriter, dataStore *DataStore, key string, replicas []string, readQuorum int) {
+	answers, err := c.gather(dataStore, key, replicas, readQuorum)
+	if err != nil {
+		writeError(w, 503, err.Error())
+		return
+	}
+
+	newest := newestAnswer(answers)
+	if newest == nil {
+		writeError(w, 404, fmt.Sprintf("%s: %q", ErrKeyNotFound, key))
+		return
+	}
+	c.repair(dataStore, key, *newest, answers)
+
+	if newest.Deleted {
+		writeError(w, 404, fmt.Sprintf("%s: %q", ErrKeyNotFound, key))
+		return
+	}
+
+	w.Header().Set(versionHeader, newest.Version.String())
+	writeJSON(w, 200, map[string]interface{}{
+		"key":   key,
+		"value": newest.Value,
+	})
+}
+
+// gather collects the entries of the key held by this node and by the
+// other replicas, returning once readQuorum replicas, counting this one,
+// have answered, or an error once too many have failed to.
+func (c *Cluster) gather(dataStore *DataStore, key string, replicas []string, readQuorum int) ([]answer, error) {
+	answers := make([]answer, 0, len(replicas))
+	entry, found := dataStore.Lookup(key)
+	answers = append(answers, answer{replica: c.Self, entry: entry, found: found})
+
+	results := make(chan answer, len(replicas))
+	others := 0
+	for _, replica := range replicas {
+		if replica == c.Self {
+			continue
+		}
+		others++
+
+		go func(replica string) {
+			entry, found, err := c.fetch(replica, key)
+			results <- answer{replica, entry, found, err}
+		}(replica)
+	}
+
+	failed := 0
+	for len(answers) < readQuorum && len(answers)+failed < 1+others {
+		result := <-results
+		if result.err != nil {
+			fmt.Println("read failed:", result.err)
+			failed++
+			continue
+		}
+		answers = append(answers, result)
+	}
+
+	if len(answers) < readQuorum {
+		return nil, fmt.Errorf("only %d of %d replicas answered", len(answers), readQuorum)
+	}
+	return answers, nil
+}
+
+// newestAnswer returns the newest entry among the answers, a tombstone
+// included, or nil if no replica holds the key: conflicting entries
+// resolve to the newest version.
+func newestAnswer(answers []answer) *Entry {
+	var newest *Entry
+	for i := range answers {
+		if answers[i].found && (newest == nil || answers[i].entry.Version.Newer(newest.Version)) {
+			newest = &answers[i].entry
+		}
+	}
+	return newest
+}
+
+// repair brings the replicas that answered with an older entry, or
+// with none, up to date: this node right away and the others in the
+// background.
+func (c *Cluster) repair(dataStore *DataStore, key string, newest Entry, answers []answer) {
+	for _, a := range answers {
+		if a.found && !newest.Version.Newer(a.entry.Version) {
+			continue
+		}
+
+		if a.replica == c.Self {
+			dataStore.Merge(key, newest)
+			continue
+		}
+		go func(replica string) {
+			if err := c.push(replica, key, newest); err != nil {
+				fmt.Println("read repair failed:", err)
+			}
+		}(a.replica)
+	}
+}
+
+// proxy passes the request on to the first of the replicas that is up
+// and relays its response. Only a replica that cannot be reached is
+// skipped: any answer, a server error included, is relayed as is, since
+// a coordinator that failed a write quorum has already applied the write
+// and sending it to the next replica would apply it twice.
+func (c *Cluster) proxy(w http.ResponseWriter, r *http.Request, replicas []string) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxValueSize))
+	if err != nil {
+		writeError(w, 413, "value too large")
+		return
+	}
+
+	if !c.forward(w, r, body, replicas) {
+		writeError(w, 503, "no replica of the key is reachable")
+	}
+}
+
+// forward sends the request with the given body to the first of the
+// replicas that is up and relays its response. It reports false, having
+// written nothing, when none of them can be reached.
+func (c *Cluster) forward(w http.ResponseWriter, r *http.Request, body []byte, replicas []string) bool {
+	for _, replica := range replicas {
+		request, err := http.NewRequest(r.Method, replica+r.URL.RequestURI(), bytes.NewReader(body))
+		if err != nil {
+			writeError(w, 500, err.Error())
+			return true
+		}
+		if contentType := r.Header.Get("Content-Type"); contentType != "" {
+			request.Header.Set("Content-Type", contentType)
+		}
+
+		response, err := c.client.Do(request)
+		if err != nil {
+			continue
+		}
+		contents, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			continue
+		}
+
+		for _, name := range []string{"Content-Type", "Allow", acksHeader, versionHeader} {
+			if value := response.Header.Get(name); value != "" {
+				w.Header().Set(name, value)
+			}
+		}
+		w.WriteHeader(response.StatusCode)
+		w.Write(contents)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SamSirsikar/DistributedSystems/kvclient"
+	"github.com/SamSirsikar/DistributedSystems/partition"
+	"github.com/stretchr/testify/assert"
+)
+
+// testCluster is a replicated cluster of in-process nodes.
+type testCluster struct {
+	servers map[string]*httptest.Server
+	stores  map[string]*DataStore
+	// partitioner places the keys as the nodes do
+	partitioner partition.Partitioner
+}
+
+func startCluster(t *testing.T, nodes int, replicas int) *testCluster {
+	return startClusterWith(t, nodes, replicas, "ring")
+}
+
+// startClusterWith starts a cluster whose nodes place the keys with the
+// named partitioner.
+func startClusterWith(t *testing.T, nodes int, replicas int, strategy string) *testCluster {
+	c := &testCluster{
+		servers: make(map[string]*httptest.Server),
+		stores:  make(map[string]*DataStore),
+	}
+
+	// listen first, so that every node knows all the urls
+	urls := make([]string, 0)
+	for i := 0; i < nodes; i++ {
+		server := httptest.NewUnstartedServer(nil)
+		url := "http://" + server.Listener.Addr().String()
+		c.servers[url] = server
+		urls = append(urls, url)
+	}
+
+	var err error
+	if c.partitioner, err = newPartitioner(strategy, urls); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, url := range urls {
+		cluster := NewCluster(url, urls, replicas)
+		cluster.Partitioner, _ = newPartitioner(strategy, urls)
+
+		c.stores[url] = NewDataStore()
+		c.servers[url].Config.Handler = NewNodeHandler(c.stores[url], cluster)
+		c.servers[url].Start()
+	}
+
+	t.Cleanup(func() {
+		for _, server := range c.servers {
+			server.Close()
+		}
+	})
+	return c
+}
+
+// replicasOf returns the n nodes that store key, primary first.
+func (c *testCluster) replicasOf(key string, n int) []string {
+	nodes, _ := c.partitioner.GetN(key, n)
+	return nodes
+}
+
+// client returns a client of the cluster for keys stored on replicas
+// nodes.
+func (c *testCluster) client(t *testing.T, replicas int) *kvclient.Client {
+	urls := make([]string, 0, len(c.servers))
+	for url := range c.servers {
+		urls = append(urls, url)
+	}
+
+	client, err := kvclient.New("ring", urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Replicas = replicas
+	return client
+}
+
+// holders returns the nodes whose store has the key with the value.
+func (c *testCluster) holders(key string, val string) []string {
+	nodes := make([]string, 0)
+	for url, store := range c.stores {
+		if got, err := store.Get(key); err == nil && got == val {
+			nodes = append(nodes, url)
+		}
+	}
+	return nodes
+}
+
+// nonReplica returns a node that does not store key.
+func (c *testCluster) nonReplica(key string, replicas int) string {
+	for url := range c.servers {
+		if !contains(c.replicasOf(key, replicas), url) {
+			return url
+		}
+	}
+	return ""
+}
+
+func contains(nodes []string, node string) bool {
+	for _, n := range nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
+func send(t *testing.T, method string, url string, body string) *http.Response {
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	assert.NoError(t, err)
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	response.Body.Close()
+	return response
+}
+
+func TestReplicatedWrites(t *testing.T) {
+	assert := assert.New(t)
+	c := startCluster(t, 5, 3)
+
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprint("key", i)
+		replicas := c.replicasOf(key, 3)
+
+		// wait for every replica, so the check below sees them all
+		response := send(t, "PUT", replicas[0]+"/"+key+"?w=3", "A")
+		assert.Equal(204, response.StatusCode)
+		assert.Equal("3", response.Header.Get(acksHeader))
+
+		// only the replicas hold the key
+		assert.ElementsMatch(replicas, c.holders(key, "A"))
+	}
+}
+
+func TestReplicatedDeleteAndAtomicOperations(t *testing.T) {
+	assert := assert.New(t)
+	c := startCluster(t, 4, 2)
+	replicas := c.replicasOf("count", 2)
+
+	// the coordinator evaluates the increment and forwards the result
+	send(t, "POST", replicas[1]+"/count/incr/5", "")
+	send(t, "POST", replicas[0]+"/count/incr/2", "")
+	assert.ElementsMatch(replicas, c.holders("count", "7"))
+
+	response := send(t, "POST", replicas[0]+"/count/cas/7/ten", "")
+	assert.Equal(204, response.StatusCode)
+	assert.ElementsMatch(replicas, c.holders("count", "ten"))
+
+	// a failed compare and swap changes nothing, so nothing is forwarded
+	response = send(t, "POST", replicas[0]+"/count/cas/7/eleven", "")
+	assert.Equal(409, response.StatusCode)
+	assert.Empty(response.Header.Get(acksHeader))
+
+	send(t, "DELETE", replicas[1]+"/count", "")
+	assert.Empty(c.holders("count", "ten"))
+}
+
+func TestAtomicOperationsRunOnThePrimary(t *testing.T) {
+	assert := assert.New(t)
+	c := startCluster(t, 5, 3)
+	replicas := c.replicasOf("count", 3)
+
+	// a write the second replica missed
+	send(t, "PUT", replicas[0]+"/count", "5")
+	c.stores[replicas[0]].Set("count", "10")
+	c.stores[replicas[2]].Set("count", "10")
+
+	// the increment it takes still builds on that write
+	response := send(t, "POST", replicas[1]+"/count/incr/1", "")
+	assert.Equal(200, response.StatusCode)
+	assert.ElementsMatch(replicas, c.holders("count", "11"))
+
+	// and concurrent increments through every replica are all counted
+	done := make(chan bool)
+	for i := 0; i < 30; i++ {
+		go func(replica string) {
+			send(t, "POST", replica+"/count/incr/1", "")
+			done <- true
+		}(replicas[i%3])
+	}
+	for i := 0; i < 30; i++ {
+		<-done
+	}
+	assert.ElementsMatch(replicas, c.holders("count", "41"))
+
+	// with the primary down the next replica takes over
+	c.servers[replicas[0]].Close()
+	response = send(t, "POST", replicas[2]+"/count/incr/1", "")
+	assert.Equal(200, response.StatusCode)
+	assert.ElementsMatch(replicas[1:], c.holders("count", "42"))
+}
+
+func TestNonReplicaProxiesToReplicas(t *testing.T) {
+	assert := assert.New(t)
+	c := startCluster(t, 5, 2)
+	other := c.nonReplica("name", 2)
+
+	response := send(t, "PUT", other+"/name", "Sam")
+	assert.Equal(204, response.StatusCode)
+	assert.Equal("2", response.Header.Get(acksHeader))
+	assert.ElementsMatch(c.replicasOf("name", 2), c.holders("name", "Sam"))
+
+	response, body := call(t, "GET", other+"/name", "")
+	assert.Equal(200, response.StatusCode)
+	assert.Equal(`{"key":"name","value":"Sam"}`, body)
+}
+
+func TestReadsFallBackWhenPrimaryIsDown(t *testing.T) {
+	assert := assert.New(t)
+	c := startCluster(t, 5, 3)
+	replicas := c.replicasOf("1", 3)
+	client := c.client(t, 3)
+
+	assert.NoError(client.Put("1", "A"))
+	assert.Eventually(func() bool {
+		return len(c.holders("1", "A")) == 3
+	}, time.Second, 10*time.Millisecond)
+
+	// kill the primary
+	c.servers[replicas[0]].Close()
+
+	val, err := client.Get("1")
+	assert.NoError(err)
+	assert.Equal("A", val)
+
+	// a node that is not a replica skips the dead primary as well
+	response, body := call(t, "GET", c.nonReplica("1", 3)+"/1", "")
+	assert.Equal(200, response.StatusCode)
+	assert.Equal(`{"key":"1","value":"A"}`, body)
+
+	// writes go to the next replica, which reaches the one left
+	assert.NoError(client.Put("1", "B"))
+	assert.ElementsMatch(replicas[1:], c.holders("1", "B"))
+
+	// with every replica down the key cannot be read
+	c.servers[replicas[1]].Close()
+	c.servers[replicas[2]].Close()
+	_, err = client.Get("1")
+	var unavailable *kvclient.UnavailableError
+	assert.ErrorAs(err, &unavailable)
+	response, _ = call(t, "GET", c.nonReplica("1", 3)+"/1", "")
+	assert.Equal(503, response.StatusCode)
+}
+
+func TestClusterPlacesKeysWithPartitioner(t *testing.T) {
+	for _, strategy := range partition.Strategies {
+		assert := assert.New(t)
+		c := startClusterWith(t, 4, 2, strategy)
+
+		for i := 0; i < 10; i++ {
+			key := fmt.Sprint("key", i)
+
+			// any node takes the write and sends it to the replicas the
+			// partitioner picks
+			response := send(t, "PUT", c.nonReplica(key, 2)+"/"+key+"?w=2", "A")
+			assert.Equal(204, response.StatusCode, strategy)
+			assert.ElementsMatch(c.replicasOf(key, 2), c.holders(key, "A"), strategy)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// keyFilter selects the keys of a listing: those that start with prefix
+// and lie in [start, end) in byte order. Empty fields select every key.
+type keyFilter struct {
+	prefix string
+	start  string
+	end    string
+}
+
+// parseKeyFilter reads the prefix, start and end query parameters of a
+// listing.
+func parseKeyFilter(r *http.Request) keyFilter {
+	query := r.URL.Query()
+	return keyFilter{
+		prefix: query.Get("prefix"),
+		start:  query.Get("start"),
+		end:    query.Get("end"),
+	}
+}
+
+// match reports whether the filter selects the key.
+func (f keyFilter) match(key string) bool {
+	if !strings.HasPrefix(key, f.prefix) {
+		return false
+	}
+	if key < f.start {
+		return false
+	}
+	return f.end == "" || key < f.end
+}
+
+// query returns the filter as the parameters of a listing request.
+func (f keyFilter) query() string {
+	params := url.Values{}
+	if f.prefix != "" {
+		params.Set("prefix", f.prefix)
+	}
+	if f.start != "" {
+		params.Set("start", f.start)
+	}
+	if f.end != "" {
+		params.Set("end", f.end)
+	}
+	return params.Encode()
+}
+
+// listing is the answer of a single node to a cluster wide listing.
+type listing struct {
+	node    string
+	entries map[string]Entry
+	err     error
+}
+
+// scatter answers GET /?scope=cluster: it asks every node of the cluster
+// at once for its entries that match the filter, keeps the newest entry
+// of every key and leaves the deleted keys out. The nodes that cannot be
+// reached are listed in the answer instead of failing it; only the keys
+// all of whose replicas are among them are missing.
+func (c *Cluster) scatter(w http.ResponseWriter, dataStore *DataStore, filter keyFilter) {
+	results := make(chan listing, len(c.Nodes))
+	for _, node := range c.Nodes {
+		if node == c.Self {
+			results <- listing{node: node, entries: filterEntries(dataStore.Entries(KeyRange{}), filter)}
+			continue
+		}
+		go func(node string) {
+			entries, err := c.export(node, filter)
+			results <- listing{node, entries, err}
+		}(node)
+	}
+
+	newest := make(map[string]Entry)
+	unreachable := make([]string, 0)
+	for range c.Nodes {
+		result := <-results
+		if result.err != nil {
+			fmt.Println("listing failed:", result.err)
+			unreachable = append(unreachable, result.node)
+			continue
+		}
+		for key, entry := range result.entries {
+			if current, ok := newest[key]; !ok || entry.Version.Newer(current.Version) {
+				newest[key] = entry
+			}
+		}
+	}
+	sort.Strings(unreachable)
+
+	entries := make([]map[string]interface{}, 0, len(newest))
+	for key, entry := range newest {
+		if entry.Deleted {
+			continue
+		}
+		entries = append(entries, map[string]interface{}{
+			"key":   key,
+			"value": entry.Value,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i]["key"].(string) < entries[j]["key"].(string)
+	})
+
+	writeJSON(w, 200, map[string]interface{}{
+		"entries":     entries,
+		"unreachable": unreachable,
+	})
+}
+
+// export reads the entries of a single node that match the filter, with
+// their versions and tombstones, from its transfer stream.
+func (c *Cluster) export(node string, filter keyFilter) (map[string]Entry, error) {
+	request, err := http.NewRequest("GET", node+"/?"+filter.query(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set(replicaHeader, c.Self)
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("%s: status %d", node, response.StatusCode)
+	}
+
+	entries := make(map[string]Entry)
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 64*1024), 2*maxValueSize)
+	for scanner.Scan() {
+		var entry transferEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s: %w", node, err)
+		}
+		entries[entry.Key] = entry.Entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", node, err)
+	}
+	return entries, nil
+}
+
+// filterEntries keeps the entries whose key the filter selects.
+func filterEntries(entries map[string]Entry, filter keyFilter) map[string]Entry {
+	for key := range entries {
+		if !filter.match(key) {
+			delete(entries, key)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SamSirsikar/DistributedSystems/kvclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyFilterMatch(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(keyFilter{}.match("anything"))
+	assert.True(keyFilter{prefix: "user/"}.match("user/42"))
+	assert.False(keyFilter{prefix: "user/"}.match("users"))
+	assert.True(keyFilter{start: "b", end: "d"}.match("b"))
+	assert.True(keyFilter{start: "b", end: "d"}.match("cz"))
+	assert.False(keyFilter{start: "b", end: "d"}.match("d"))
+	assert.False(keyFilter{start: "b", end: "d"}.match("a"))
+	assert.False(keyFilter{prefix: "user/", end: "user/2"}.match("user/3"))
+
+	assert.Equal("", keyFilter{}.query())
+	assert.Equal("end=z&prefix=user%2F", keyFilter{prefix: "user/", end: "z"}.query())
+}
+
+func TestClusterListsEveryNode(t *testing.T) {
+	assert := assert.New(t)
+	c := startCluster(t, 4, 2)
+	client := c.client(t, 2)
+
+	for _, key := range []string{"a", "user/1", "user/2", "user/3", "z"} {
+		assert.NoError(client.Put(key, key))
+	}
+	assert.NoError(client.Delete("user/3"))
+	future := Version{Counter: uint64(time.Now().Add(time.Hour).UnixNano()), Node: "test"}
+
+	// a write that only reached the second replica is the newest
+	replicas := c.replicasOf("user/2", 2)
+	applied, err := c.stores[replicas[1]].Merge("user/2", Entry{Value: "B", Version: future})
+	assert.NoError(err)
+	assert.True(applied)
+
+	// and so is a delete that only reached the first one; merging the
+	// write above moved that node's clock past future, so the delete
+	// needs a later version still
+	assert.NoError(client.Put("user/9", "x"))
+	later := Version{Counter: future.Counter + uint64(time.Hour), Node: "test"}
+	applied, err = c.stores[c.replicasOf("user/9", 1)[0]].Merge("user/9", Entry{Deleted: true, Version: later})
+	assert.NoError(err)
+	assert.True(applied)
+	_, err = client.Get("user/9")
+	assert.ErrorIs(err, kvclient.ErrNotFound)
+
+	coordinator := c.replicasOf("a", 1)[0]
+	response, body := call(t, "GET", coordinator+"/?scope=cluster&prefix=user%2F", "")
+	assert.Equal(200, response.StatusCode)
+	assert.JSONEq(`{"entries":[{"key":"user/1","value":"user/1"},{"key":"user/2","value":"B"}],"unreachable":[]}`, body)
+
+	_, body = call(t, "GET", coordinator+"/?scope=cluster&start=b&end=user%2F2", "")
+	assert.JSONEq(`{"entries":[{"key":"user/1","value":"user/1"}],"unreachable":[]}`, body)
+
+	// with a node down, the others still hold a replica of every key,
+	// and the newest entries live on other nodes
+	down := ""
+	for url := range c.servers {
+		if url != coordinator && url != replicas[1] && url != c.replicasOf("user/9", 1)[0] {
+			down = url
+		}
+	}
+	c.servers[down].Close()
+	response, body = call(t, "GET", coordinator+"/?scope=cluster", "")
+	assert.Equal(200, response.StatusCode)
+	assert.JSONEq(`{"entries":[
+		{"key":"a","value":"a"},
+		{"key":"user/1","value":"user/1"},
+		{"key":"user/2","value":"B"},
+		{"key":"z","value":"z"}
+	],"unreachable":["`+down+`"]}`, body)
+
+	// the client scans the nodes itself and merges them the same way
+	result, err := client.Scan(kvclient.Filter{Prefix: "user/"})
+	assert.NoError(err)
+	assert.Equal([]string{down}, result.Unreachable)
+	assert.Equal([]kvclient.Entry{{Key: "user/1", Value: "user/1"}, {Key: "user/2", Value: "B"}}, result.Entries)
+}
@@ -4,57 +4,581 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
-	"os"
+	"net/url"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 )
 
+var (
+	// ErrKeyNotFound is returned when an operation needs an existing key.
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrNotInteger is returned when incrementing a non-numeric value.
+	ErrNotInteger = errors.New("value is not an integer")
+)
+
+// maxValueSize limits the size of a request body holding a value.
+const maxValueSize = 1 << 20
+
+// DataStore is a key value store that is safe for concurrent use by the
+// HTTP handlers of a server. A store opened with OpenDataStore logs every
+// mutation before applying it, so it can be recovered after a restart.
 type DataStore struct {
-	Data map[int]string
+	mu   sync.RWMutex
+	data map[string]string
+	// versions holds the version of the last write to every key,
+	// including the keys that were deleted
+	versions map[string]Version
+	// node and clock issue the versions of local writes
+	node  string
+	clock uint64
+	// wal is nil for a store that only lives in memory
+	wal *wal
 }
 
-func (d *DataStore) Get(key int) (string, error) {
-	if val, ok := d.Data[key]; ok {
-		// the key exists in the data store.
-		// return the value associated with the key.
+func (d *DataStore) Get(key string) (string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if val, ok := d.data[key]; ok {
+		// return the value if found
 		return val, nil
 	}
 
-	// the data store does not have the key.
-	// return an error with an appropriate message.
-	return "", errors.New(fmt.Sprintf("Key %d not found.", key))
+	// return an error if the key is not found
+	return "", fmt.Errorf("%w: %q", ErrKeyNotFound, key)
 }
 
-func (d *DataStore) Set(key int, val string) error {
-	// set the val for the key in the data store.
-	d.Data[key] = val
+func (d *DataStore) Set(key string, val string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-	// no error to return
-	return nil
+	// set the val for the key in the data store.
+	return d.commit(record{Op: opSet, Key: key, Value: val})
 }
 
-func (d *DataStore) UnSet(key int) error {
+func (d *DataStore) UnSet(key string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	// unset the key in the data store.
-	// ignore if the key doesn't exist
-	delete(d.Data, key)
+	// ignore if the key is already deleted. a key that was never written
+	// still gets a tombstone, so that the delete has a version that
+	// reaches the other replicas
+	if _, ok := d.data[key]; !ok {
+		if _, deleted := d.versions[key]; deleted {
+			return nil
+		}
+	}
+
+	return d.commit(record{Op: opDelete, Key: key})
+}
+
+// CompareAndSwap sets the key to newVal only if it currently holds
+// oldVal, and reports whether the swap happened.
+func (d *DataStore) CompareAndSwap(key string, oldVal string, newVal string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	val, ok := d.data[key]
+	if !ok {
+		return false, fmt.Errorf("%w: %q", ErrKeyNotFound, key)
+	}
+	if val != oldVal {
+		return false, nil
+	}
+
+	if err := d.commit(record{Op: opSet, Key: key, Value: newVal}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PutIfAbsent sets the key only if it does not exist yet. It returns the
+// value now stored and whether it was the one just put.
+func (d *DataStore) PutIfAbsent(key string, val string) (string, bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if existing, ok := d.data[key]; ok {
+		return existing, false, nil
+	}
+
+	if err := d.commit(record{Op: opSet, Key: key, Value: val}); err != nil {
+		return "", false, err
+	}
+	return val, true, nil
+}
 
-	// no error to return
+// Increment adds delta to the integer stored at key, treating a missing
+// key as 0, and returns the new value.
+func (d *DataStore) Increment(key string, delta int) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	current := 0
+	if val, ok := d.data[key]; ok {
+		var err error
+		if current, err = strconv.Atoi(val); err != nil {
+			return 0, fmt.Errorf("%w: %q holds %q", ErrNotInteger, key, val)
+		}
+	}
+
+	current += delta
+	if err := d.commit(record{Op: opSet, Key: key, Value: strconv.Itoa(current)}); err != nil {
+		return 0, err
+	}
+	return current, nil
+}
+
+// All returns a copy of every key and value in the data store.
+func (d *DataStore) All() map[string]string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	data := make(map[string]string, len(d.data))
+	for key, val := range d.data {
+		data[key] = val
+	}
+	return data
+}
+
+// commit logs a mutation and then applies it, compacting the log once it
+// has grown long enough. A record without a version is a local write and
+// gets the next version of this store. The caller must hold the write
+// lock.
+func (d *DataStore) commit(r record) error {
+	if r.Version == (Version{}) {
+		r.Version = d.nextVersion()
+	}
+	d.observe(r.Version)
+
+	if d.wal == nil {
+		return r.apply(d.data, d.versions)
+	}
+
+	// write ahead: a mutation that is not in the log never happened
+	if err := d.wal.append(r); err != nil {
+		return err
+	}
+	if err := r.apply(d.data, d.versions); err != nil {
+		return err
+	}
+
+	if d.wal.full() {
+		// the mutation is already durable, so a failed compaction only
+		// means the log keeps growing until the next attempt
+		if err := d.wal.snapshot(d.data, d.versions); err != nil {
+			fmt.Println("snapshot failed:", err)
+		}
+	}
 	return nil
 }
 
+// Snapshot writes the whole store to its snapshot file and truncates the
+// log. It does nothing for an in-memory store.
+func (d *DataStore) Snapshot() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.wal == nil {
+		return nil
+	}
+	return d.wal.snapshot(d.data, d.versions)
+}
+
+// Close releases the log of a persistent store.
+func (d *DataStore) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.wal == nil {
+		return nil
+	}
+	err := d.wal.close()
+	d.wal = nil
+	return err
+}
+
 func NewDataStore() *DataStore {
 	// create the data store
 	ds := DataStore{}
 	// allocate memory for the data store
-	ds.Data = make(map[int]string)
+	ds.data = make(map[string]string)
+	ds.versions = make(map[string]Version)
 
 	return &ds
 }
 
+// OpenDataStore returns a store persisted in dir, recovering the data
+// left there by a previous run from its snapshot and write-ahead log.
+func OpenDataStore(dir string, options PersistOptions) (*DataStore, error) {
+	w, state, err := openWAL(dir, options)
+	if err != nil {
+		return nil, err
+	}
+
+	ds := &DataStore{data: state.Data, versions: state.Versions, wal: w}
+	for _, version := range ds.versions {
+		// never issue a version older than a recovered one
+		ds.observe(version)
+	}
+	return ds, nil
+}
+
+// writeJSON writes v as the JSON body of a response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	// convert to JSON (ignore the error for now)
+	jsonResponse, _ := json.Marshal(v)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jsonResponse)
+}
+
+// writeError writes a JSON error body such as {"error": "key not found"}.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": message,
+	})
+}
+
+// methodNotAllowed rejects a request whose method the route does not serve.
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, 405, "method not allowed")
+}
+
+// readValue reads the value to store from a request body. A JSON body
+// must look like {"value": "A"}; any other body is taken as the raw
+// value.
+func readValue(r *http.Request) (string, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return string(body), nil
+	}
+
+	var request struct {
+		Value *string `json:"value"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return "", fmt.Errorf("invalid JSON body: %w", err)
+	}
+	if request.Value == nil {
+		return "", errors.New(`JSON body must have a string "value"`)
+	}
+	return *request.Value, nil
+}
+
+// pathSegments returns the unescaped segments that pattern captures
+// from an escaped url path, so keys may contain any character.
+func pathSegments(pattern *regexp.Regexp, path string) ([]string, error) {
+	matches := pattern.FindStringSubmatch(path)
+
+	segments := make([]string, len(matches)-1)
+	for i, match := range matches[1:] {
+		segment, err := url.PathUnescape(match)
+		if err != nil {
+			return nil, err
+		}
+		segments[i] = segment
+	}
+	return segments, nil
+}
+
+// NewStoreHandler returns the HTTP API of a single node:
+//
+//	GET    /                            all the keys and values
+//	GET    /?prefix=p&start=a&end=b     the keys with prefix p in [a, b)
+//	GET    /{key}                       a single value
+//	PUT    /{key}                       set the value from a JSON or raw body
+//	PUT    /{key}/{value}               set the value from the path
+//	DELETE /{key}                       remove the key
+//	POST   /{key}/cas/{old}/{new}       compare and swap
+//	POST   /{key}/putifabsent/{value}   set the key only if it is missing
+//	POST   /{key}/incr/{delta}          add delta to an integer value
+//
+// Requests for / with the X-Replica header move ranges of keys between
+// nodes instead, see serveTransfer.
+func NewStoreHandler(dataStore *DataStore) http.Handler {
+	// define url pattern regex
+	getAllPattern := regexp.MustCompile(`^/$`)
+	keyPattern := regexp.MustCompile(`^/([^/]+)$`)
+	putOnePattern := regexp.MustCompile(`^/([^/]+)/([^/]+)$`)
+	casPattern := regexp.MustCompile(`^/([^/]+)/cas/([^/]+)/([^/]+)$`)
+	putIfAbsentPattern := regexp.MustCompile(`^/([^/]+)/putifabsent/([^/]+)$`)
+	incrementPattern := regexp.MustCompile(`^/([^/]+)/incr/([^/]+)$`)
+
+	// define a server mux to add handlers
+	mux := http.NewServeMux()
+
+	// attach "/" match all route
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// match against the escaped path so that an encoded "/" stays
+		// inside its key
+		path := r.URL.EscapedPath()
+
+		// match the routes based on the pattern
+		switch {
+		case getAllPattern.MatchString(path):
+			if r.Header.Get(replicaHeader) != "" {
+				// another node or the rebalancer moving keys
+				serveTransfer(w, r, dataStore)
+				return
+			}
+			if r.Method != "GET" {
+				methodNotAllowed(w, "GET")
+				return
+			}
+
+			// add a copy of the data from the data store to the response slice
+			filter := parseKeyFilter(r)
+			data := dataStore.All()
+			response := make([]map[string]interface{}, 0, len(data))
+			for key, val := range data {
+				if !filter.match(key) {
+					continue
+				}
+				response = append(response, map[string]interface{}{
+					"key":   key,
+					"value": val,
+				})
+			}
+
+			// sort by key so the listing is stable between requests
+			sort.Slice(response, func(i, j int) bool {
+				return response[i]["key"].(string) < response[j]["key"].(string)
+			})
+
+			writeJSON(w, 200, response)
+		case keyPattern.MatchString(path):
+			segments, err := pathSegments(keyPattern, path)
+			if err != nil {
+				writeError(w, 400, err.Error())
+				return
+			}
+			key := segments[0]
+
+			switch r.Method {
+			case "GET":
+				// get the value from the data store
+				val, err := dataStore.Get(key)
+				if err != nil {
+					writeError(w, 404, err.Error())
+					return
+				}
+
+				// generate the json response using the key and value
+				writeJSON(w, 200, map[string]interface{}{
+					"key":   key,
+					"value": val,
+				})
+			case "PUT":
+				// the value is in the request body
+				r.Body = http.MaxBytesReader(w, r.Body, maxValueSize)
+				val, err := readValue(r)
+				if err != nil {
+					var tooLarge *http.MaxBytesError
+					if errors.As(err, &tooLarge) {
+						writeError(w, 413, "value too large")
+					} else {
+						writeError(w, 400, err.Error())
+					}
+					return
+				}
+
+				if err := dataStore.Set(key, val); err != nil {
+					writeError(w, 500, err.Error())
+					return
+				}
+				w.WriteHeader(204)
+			case "DELETE":
+				if err := dataStore.UnSet(key); err != nil {
+					writeError(w, 500, err.Error())
+					return
+				}
+				w.WriteHeader(204)
+			default:
+				methodNotAllowed(w, "GET", "PUT", "DELETE")
+			}
+		case putOnePattern.MatchString(path):
+			if r.Method != "PUT" {
+				methodNotAllowed(w, "PUT")
+				return
+			}
+			segments, err := pathSegments(putOnePattern, path)
+			if err != nil {
+				writeError(w, 400, err.Error())
+				return
+			}
+
+			// PUT /{key}/{value} with the value in the url
+			// example: PUT /1/A saves the value A at key 1
+			if err := dataStore.Set(segments[0], segments[1]); err != nil {
+				writeError(w, 500, err.Error())
+				return
+			}
+			w.WriteHeader(204)
+		case casPattern.MatchString(path):
+			if r.Method != "POST" {
+				methodNotAllowed(w, "POST")
+				return
+			}
+			segments, err := pathSegments(casPattern, path)
+			if err != nil {
+				writeError(w, 400, err.Error())
+				return
+			}
+
+			swapped, err := dataStore.CompareAndSwap(segments[0], segments[1], segments[2])
+			switch {
+			case errors.Is(err, ErrKeyNotFound):
+				writeError(w, 404, err.Error())
+			case err != nil:
+				writeError(w, 500, err.Error())
+			case !swapped:
+				// the current value did not match
+				writeError(w, 409, "current value does not match")
+			default:
+				w.WriteHeader(204)
+			}
+		case putIfAbsentPattern.MatchString(path):
+			if r.Method != "POST" {
+				methodNotAllowed(w, "POST")
+				return
+			}
+			segments, err := pathSegments(putIfAbsentPattern, path)
+			if err != nil {
+				writeError(w, 400, err.Error())
+				return
+			}
+
+			val, stored, err := dataStore.PutIfAbsent(segments[0], segments[1])
+			if err != nil {
+				writeError(w, 500, err.Error())
+				return
+			}
+
+			// respond with the value that is now stored
+			status := 201
+			if !stored {
+				status = 409
+			}
+			writeJSON(w, status, map[string]interface{}{
+				"key":   segments[0],
+				"value": val,
+			})
+		case incrementPattern.MatchString(path):
+			if r.Method != "POST" {
+				methodNotAllowed(w, "POST")
+				return
+			}
+			segments, err := pathSegments(incrementPattern, path)
+			if err != nil {
+				writeError(w, 400, err.Error())
+				return
+			}
+			delta, err := strconv.Atoi(segments[1])
+			if err != nil {
+				writeError(w, 400, "invalid delta")
+				return
+			}
+
+			val, err := dataStore.Increment(segments[0], delta)
+			if errors.Is(err, ErrNotInteger) {
+				// the stored value is not a number
+				writeError(w, 409, err.Error())
+				return
+			}
+			if err != nil {
+				writeError(w, 500, err.Error())
+				return
+			}
+
+			writeJSON(w, 200, map[string]interface{}{
+				"key":   segments[0],
+				"value": val,
+			})
+		default:
+			// no such route found
+			writeError(w, 404, "no such route")
+		}
+	})
+
+	return mux
+}
+
 type HTTPServer struct {
 	Ports []int
+	// DataDir holds a log and snapshot for every node in DataDir/{port}.
+	// The nodes only keep their data in memory when it is empty.
+	DataDir string
+	// Persist tunes the logs of the nodes when DataDir is set.
+	Persist PersistOptions
+	// Replicas is the number of nodes that store every key. The nodes
+	// work on their own, without forwarding anything, when it is zero.
+	Replicas int
+	// ReadQuorum and WriteQuorum are the default number of replicas a
+	// read or write waits for; zero means a majority of Replicas.
+	ReadQuorum  int
+	WriteQuorum int
+	// Partitioner names the strategy that places the keys on the nodes,
+	// one of partition.Strategies. Empty means the consistent hash ring.
+	Partitioner string
+
+	mu      sync.Mutex
+	servers map[int]*http.Server
+}
+
+// nodeURL is the base url of the node on the given port.
+func nodeURL(port int) string {
+	return fmt.Sprintf("http://localhost:%d", port)
+}
+
+// handler returns the handler of the node on the given port.
+func (h *HTTPServer) handler(port int, dataStore *DataStore) (http.Handler, error) {
+	if h.Replicas <= 0 {
+		return NewStoreHandler(dataStore), nil
+	}
+
+	nodes := make([]string, 0, len(h.Ports))
+	for _, p := range h.Ports {
+		nodes = append(nodes, nodeURL(p))
+	}
+	cluster := NewCluster(nodeURL(port), nodes, h.Replicas)
+	if h.Partitioner != "" {
+		partitioner, err := newPartitioner(h.Partitioner, nodes)
+		if err != nil {
+			return nil, err
+		}
+		cluster.Partitioner = partitioner
+	}
+	if h.ReadQuorum > 0 {
+		cluster.ReadQuorum = h.ReadQuorum
+	}
+	if h.WriteQuorum > 0 {
+		cluster.WriteQuorum = h.WriteQuorum
+	}
+	return NewNodeHandler(dataStore, cluster), nil
+}
+
+// openStore creates the data store of the node on the given port.
+func (h *HTTPServer) openStore(port int) (*DataStore, error) {
+	if h.DataDir == "" {
+		return NewDataStore(), nil
+	}
+	return OpenDataStore(filepath.Join(h.DataDir, strconv.Itoa(port)), h.Persist)
 }
 
 func (h *HTTPServer) Start() {
@@ -66,103 +590,40 @@ func (h *HTTPServer) Start() {
 		go func() {
 			fmt.Println("starting server at port:", h.Ports[index])
 
-			// create a data store to be used by this server
-			dataStore := NewDataStore()
-
-			// define a server mux to add handlers
-			mux := http.NewServeMux()
-
-			// define url pattern regex
-			getAllPattern := regexp.MustCompile(`^/$`)
-			getOnePattern := regexp.MustCompile(`^/([0-9]+)$`)
-			putOnePattern := regexp.MustCompile(`^/([0-9]+)/([0-9a-zA-Z]+)$`)
-
-			// attach "/" match all route
-			mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-				// match the routes based on the pattern
-				switch {
-				case getAllPattern.MatchString(r.URL.Path):
-					if r.Method == "GET" {
-						// create a slice with map (for 10 objects to start with)
-						// where map's key is string and value is either int or string (interface{} type)
-						response := make([]map[string]interface{}, 0)
-
-						// add the data from the data store to the response slice
-						for key, val := range dataStore.Data {
-							response = append(response, map[string]interface{}{
-								"key":   key,
-								"value": val,
-							})
-						}
-
-						// convert to JSON (ignore the error for now)
-						jsonResponse, _ := json.Marshal(response)
-
-						// update the writer with response
-						w.WriteHeader(200)
-						w.Write(jsonResponse)
-					} else {
-						// no other method allowed on this route
-						w.WriteHeader(405)
-					}
-				case getOnePattern.MatchString(r.URL.Path):
-					if r.Method == "GET" {
-						// get the key from url
-						matches := getOnePattern.FindAllStringSubmatch(r.URL.Path, -1)
-
-						// extract the key from matches
-						// and convert it to integer
-						// ignore the error for now
-						// [['/1', '1']] --> matches[0]: ['/1', '1'] --> matches[0][1]: '1'
-						key, _ := strconv.Atoi(matches[0][1])
-
-						// get the value from the data store
-						val, err := dataStore.Get(key)
-						if err != nil {
-							w.WriteHeader(404)
-						} else {
-							// generate the json response using the key and value
-							jsonResponse, _ := json.Marshal(map[string]interface{}{
-								"key":   key,
-								"value": val,
-							})
-
-							// update the writer with response
-							w.WriteHeader(200)
-							w.Write(jsonResponse)
-						}
-					} else {
-						// no other method allowed on this route
-						w.WriteHeader(405)
-					}
-				case putOnePattern.MatchString(r.URL.Path):
-					if r.Method == "PUT" {
-						// get the key and from url
-						matches := putOnePattern.FindAllStringSubmatch(r.URL.Path, -1)
-
-						// extract key and value from the matches
-						key, _ := strconv.Atoi(matches[0][1])
-						val := matches[0][2]
-
-						// add the key and value to the data store
-						dataStore.Set(key, val)
-
-						w.WriteHeader(204)
-					} else {
-						// no other method allowed on this route
-						w.WriteHeader(405)
-					}
-				default:
-					// no such route found
-					w.WriteHeader(404)
-				}
-			})
+			// create a data store to be used by this server,
+			// recovering whatever a previous run left on disk
+			dataStore, err := h.openStore(h.Ports[index])
+			if err != nil {
+				fmt.Println("failed to open the data store for port", h.Ports[index], err)
+				done <- true
+				return
+			}
+
+			handler, err := h.handler(h.Ports[index], dataStore)
+			if err != nil {
+				fmt.Println("failed to start the server at port", h.Ports[index], err)
+				dataStore.Close()
+				done <- true
+				return
+			}
+
+			server := &http.Server{
+				Addr:    fmt.Sprintf(":%d", h.Ports[index]),
+				Handler: handler,
+			}
+			h.mu.Lock()
+			if h.servers == nil {
+				h.servers = make(map[int]*http.Server)
+			}
+			h.servers[h.Ports[index]] = server
+			h.mu.Unlock()
 
 			// listen and serve http requests
-			http.ListenAndServe(fmt.Sprintf(":%d", h.Ports[index]), mux)
+			server.ListenAndServe()
 
 			// signal the goroutine end
 			fmt.Println("shutting down server at port:", h.Ports[index])
+			dataStore.Close()
 			done <- true
 		}()
 	}
@@ -180,6 +641,19 @@ func (h *HTTPServer) Start() {
 	}
 }
 
+// Stop shuts the node on the given port down, as if it had failed. Start
+// returns once every node is stopped.
+func (h *HTTPServer) Stop(port int) error {
+	h.mu.Lock()
+	server, ok := h.servers[port]
+	h.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no server at port %d", port)
+	}
+	return server.Close()
+}
+
 func NewHTTPServer(ports []int) *HTTPServer {
 	// create a http server instance
 	hs := HTTPServer{}
@@ -189,29 +663,3 @@ func NewHTTPServer(ports []int) *HTTPServer {
 
 	return &hs
 }
-
-func main() {
-	// generate port numbers
-	if len(os.Args) < 2 {
-		fmt.Println("usage: go run server.go 8001-8005")
-		os.Exit(1)
-	}
-
-	// get the start and end ports
-	startEndPort := strings.Split(os.Args[1], "-")
-	startPort, _ := strconv.Atoi(startEndPort[0])
-	endPort, _ := strconv.Atoi(startEndPort[1])
-
-	// create a ports array to store all the ports
-	ports := make([]int, 0)
-	for i := startPort; i <= endPort; i++ {
-		ports = append(ports, i)
-	}
-
-	// create a http server instance
-	// with the required number of servers
-	server := NewHTTPServer(ports)
-
-	// start all the servers
-	server.Start()
-}
\ No newline at end of file
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// do sends a request to the handler and returns the status and body.
+func do(handler http.Handler, method string, path string, contentType string, body string) (int, string) {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder.Code, recorder.Body.String()
+}
+
+func TestStoreHandler(t *testing.T) {
+	handler := NewStoreHandler(NewDataStore())
+
+	tests := []struct {
+		method      string
+		path        string
+		contentType string
+		body        string
+		status      int
+		response    string
+	}{
+		{"PUT", "/1/A", "", "", 204, ""},
+		{"GET", "/1", "", "", 200, `{"key":"1","value":"A"}`},
+		{"PUT", "/name", "text/plain", "raw value", 204, ""},
+		{"PUT", "/user%2F42", "application/json; charset=utf-8", `{"value":"Sam"}`, 204, ""},
+		{"GET", "/user%2F42", "", "", 200, `{"key":"user/42","value":"Sam"}`},
+		{"PUT", "/raw", "", `{"value":"Sam"}`, 204, ""},
+		{"GET", "/raw", "", "", 200, `{"key":"raw","value":"{\"value\":\"Sam\"}"}`},
+		{"PUT", "/hello%20world/a%3Fb", "", "", 204, ""},
+		{"GET", "/hello%20world", "", "", 200, `{"key":"hello world","value":"a?b"}`},
+		{"PUT", "/bad", "application/json", `{"other":1}`, 400, `{"error":"JSON body must have a string \"value\""}`},
+		{"PUT", "/bad", "application/json", `not json`, 400, `{"error":"invalid JSON body: invalid character 'o' in literal null (expecting 'u')"}`},
+		{"GET", "/", "", "", 200, `[{"key":"1","value":"A"},{"key":"hello world","value":"a?b"},{"key":"name","value":"raw value"},{"key":"raw","value":"{\"value\":\"Sam\"}"},{"key":"user/42","value":"Sam"}]`},
+		{"GET", "/?prefix=user%2F", "", "", 200, `[{"key":"user/42","value":"Sam"}]`},
+		{"GET", "/?start=1&end=name", "", "", 200, `[{"key":"1","value":"A"},{"key":"hello world","value":"a?b"}]`},
+		{"GET", "/?start=name", "", "", 200, `[{"key":"name","value":"raw value"},{"key":"raw","value":"{\"value\":\"Sam\"}"},{"key":"user/42","value":"Sam"}]`},
+		{"GET", "/?prefix=none", "", "", 200, `[]`},
+		{"DELETE", "/name", "", "", 204, ""},
+		{"DELETE", "/name", "", "", 204, ""},
+		{"GET", "/name", "", "", 404, `{"error":"key not found: \"name\""}`},
+		{"POST", "/name", "", "", 405, `{"error":"method not allowed"}`},
+		{"POST", "/1/cas/A/B", "", "", 204, ""},
+		{"POST", "/1/cas/A/C", "", "", 409, `{"error":"current value does not match"}`},
+		{"POST", "/2/cas/A/C", "", "", 404, `{"error":"key not found: \"2\""}`},
+		{"POST", "/3/putifabsent/X", "", "", 201, `{"key":"3","value":"X"}`},
+		{"POST", "/3/putifabsent/Y", "", "", 409, `{"key":"3","value":"X"}`},
+		{"POST", "/count/incr/5", "", "", 200, `{"key":"count","value":5}`},
+		{"POST", "/count/incr/-7", "", "", 200, `{"key":"count","value":-2}`},
+		{"POST", "/count/incr/x", "", "", 400, `{"error":"invalid delta"}`},
+		{"POST", "/3/incr/1", "", "", 409, `{"error":"value is not an integer: \"3\" holds \"X\""}`},
+		{"GET", "/a/b/c/d/e", "", "", 404, `{"error":"no such route"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method+" "+test.path, func(t *testing.T) {
+			status, response := do(handler, test.method, test.path, test.contentType, test.body)
+			assert.Equal(t, test.status, status)
+			assert.Equal(t, test.response, response)
+		})
+	}
+}
+
+func TestStoreHandlerHeaders(t *testing.T) {
+	assert := assert.New(t)
+	handler := NewStoreHandler(NewDataStore())
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/missing", nil))
+	assert.Equal("application/json", recorder.Header().Get("Content-Type"))
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("PATCH", "/key", nil))
+	assert.Equal("GET, PUT, DELETE", recorder.Header().Get("Allow"))
+}
+
+func TestStoreHandlerRejectsLargeValues(t *testing.T) {
+	status, _ := do(NewStoreHandler(NewDataStore()), "PUT", "/big", "", strings.Repeat("x", maxValueSize+1))
+	assert.Equal(t, 413, status)
+}
+
+func TestDataStoreConcurrentIncrement(t *testing.T) {
+	dataStore := NewDataStore()
+	goroutines, increments := 8, 1000
+
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				dataStore.Increment("count", 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	val, err := dataStore.Get("count")
+	assert.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(goroutines*increments), val)
+}
+
+// TestHelperNode is not a real test. It runs a persistent node in a child
+// process so that TestNodeSurvivesKill can kill it without any chance to
+// clean up.
+func TestHelperNode(t *testing.T) {
+	dir := os.Getenv("LAB2_HELPER_NODE_DIR")
+	if dir == "" {
+		t.Skip("only runs as a child process")
+	}
+
+	store, err := OpenDataStore(dir, PersistOptions{SnapshotEvery: 4})
+	if err != nil {
+		fmt.Println("open failed:", err)
+		os.Exit(1)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fmt.Println("listen failed:", err)
+		os.Exit(1)
+	}
+
+	// tell the parent where to find this node
+	fmt.Println("listening on", listener.Addr())
+	http.Serve(listener, NewStoreHandler(store))
+}
+
+// startNode runs TestHelperNode on dir and returns its url.
+func startNode(t *testing.T, dir string) (*exec.Cmd, string) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperNode$")
+	cmd.Env = append(os.Environ(), "LAB2_HELPER_NODE_DIR="+dir)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if addr, ok := strings.CutPrefix(scanner.Text(), "listening on "); ok {
+			// keep draining the pipe so the child never blocks on output
+			go io.Copy(io.Discard, stdout)
+			return cmd, "http://" + addr
+		}
+	}
+
+	cmd.Process.Kill()
+	cmd.Wait()
+	t.Fatal("node did not start")
+	return nil, ""
+}
+
+func TestNodeSurvivesKill(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts child processes")
+	}
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	cmd, url := startNode(t, dir)
+
+	// enough writes to take a snapshot and leave records in the log
+	client := &http.Client{}
+	for i := 1; i <= 10; i++ {
+		request, _ := http.NewRequest("PUT", fmt.Sprintf("%s/%d", url, i), strings.NewReader(fmt.Sprint("value", i)))
+		response, err := client.Do(request)
+		assert.NoError(err)
+		assert.Equal(204, response.StatusCode)
+		response.Body.Close()
+	}
+	request, _ := http.NewRequest("DELETE", url+"/3", nil)
+	response, err := client.Do(request)
+	assert.NoError(err)
+	response.Body.Close()
+
+	// SIGKILL: no deferred Close, no final snapshot
+	assert.NoError(cmd.Process.Kill())
+	cmd.Wait()
+
+	cmd, url = startNode(t, dir)
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	response, err = http.Get(url + "/")
+	assert.NoError(err)
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+
+	expected := make([]string, 0)
+	for _, i := range []int{1, 10, 2, 4, 5, 6, 7, 8, 9} {
+		expected = append(expected, fmt.Sprintf(`{"key":"%d","value":"value%d"}`, i, i))
+	}
+	assert.Equal("["+strings.Join(expected, ",")+"]", string(body))
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/SamSirsikar/DistributedSystems/partition"
+)
+
+// KeyRange is the arc (Start, End] of the hash ring, wrapping around past
+// the largest hash when End is not after Start. A range whose Start and
+// End are equal covers the whole ring.
+type KeyRange struct {
+	Start uint32 `json:"start"`
+	End   uint32 `json:"end"`
+}
+
+// Contains reports whether a hash lies on the arc.
+func (k KeyRange) Contains(hashKey uint32) bool {
+	if k.Start < k.End {
+		return k.Start < hashKey && hashKey <= k.End
+	}
+	return hashKey > k.Start || hashKey <= k.End
+}
+
+func (k KeyRange) String() string {
+	return fmt.Sprintf("(%d, %d]", k.Start, k.End)
+}
+
+// query returns the range as the parameters of a transfer request.
+func (k KeyRange) query() string {
+	return url.Values{
+		"from": {strconv.FormatUint(uint64(k.Start), 10)},
+		"to":   {strconv.FormatUint(uint64(k.End), 10)},
+	}.Encode()
+}
+
+// parseRange reads the from and to parameters of a transfer request, and
+// returns the whole ring when both are missing.
+func parseRange(r *http.Request) (KeyRange, error) {
+	query := r.URL.Query()
+	if !query.Has("from") && !query.Has("to") {
+		return KeyRange{}, nil
+	}
+
+	start, err := strconv.ParseUint(query.Get("from"), 10, 32)
+	if err != nil {
+		return KeyRange{}, errors.New("from must be a 32 bit hash")
+	}
+	end, err := strconv.ParseUint(query.Get("to"), 10, 32)
+	if err != nil {
+		return KeyRange{}, errors.New("to must be a 32 bit hash")
+	}
+	return KeyRange{Start: uint32(start), End: uint32(end)}, nil
+}
+
+// transferEntry is a line of a transfer stream: a key with its entry.
+type transferEntry struct {
+	Key string `json:"key"`
+	Entry
+}
+
+// Entries returns the entry of every key whose hash lies in the range,
+// tombstones included, so that a delete moves along with the key.
+func (d *DataStore) Entries(keyRange KeyRange) map[string]Entry {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	entries := make(map[string]Entry)
+	for key, version := range d.versions {
+		if !keyRange.Contains(partition.RingHash(key)) {
+			continue
+		}
+		val, found := d.data[key]
+		entries[key] = Entry{Value: val, Version: version, Deleted: !found}
+	}
+	return entries
+}
+
+// Drop forgets every key whose hash lies in the range, version and all,
+// once the keys have moved to the nodes that now own them. It returns the
+// number of keys dropped.
+func (d *DataStore) Drop(keyRange KeyRange) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	dropped := 0
+	for key := range d.versions {
+		if !keyRange.Contains(partition.RingHash(key)) {
+			continue
+		}
+		if err := d.commit(record{Op: opDrop, Key: key}); err != nil {
+			return dropped, err
+		}
+		dropped++
+	}
+	return dropped, nil
+}
+
+// serveTransfer moves a range of keys in and out of a store for the
+// rebalancer. The range is given by the from and to query parameters,
+// which a DELETE must set, so that it never drops the whole ring by
+// mistake.
+// A GET also takes the prefix, start and end parameters of a listing,
+// which a node uses to list the keys of the whole cluster.
+//
+//	GET    /   stream the entries in the range, one JSON object per line
+//	POST   /   merge a stream of entries, keeping the newer version
+//	DELETE /   drop the keys in the range
+func serveTransfer(w http.ResponseWriter, r *http.Request, dataStore *DataStore) {
+	keyRange, err := parseRange(r)
+	if err != nil {
+		writeError(w, 400, err.Error())
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		entries := filterEntries(dataStore.Entries(keyRange), parseKeyFilter(r))
+		keys := make([]string, 0, len(entries))
+		for key := range entries {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(200)
+		encoder := json.NewEncoder(w)
+		for _, key := range keys {
+			if err := encoder.Encode(transferEntry{Key: key, Entry: entries[key]}); err != nil {
+				// the receiver went away
+				return
+			}
+		}
+	case "POST":
+		received, applied := 0, 0
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Buffer(make([]byte, 64*1024), 2*maxValueSize)
+		for scanner.Scan() {
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+
+			var entry transferEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				writeError(w, 400, fmt.Sprintf("entry %d: %s", received+1, err))
+				return
+			}
+			received++
+
+			ok, err := dataStore.Merge(entry.Key, entry.Entry)
+			if err != nil {
+				writeError(w, 500, err.Error())
+				return
+			}
+			if ok {
+				applied++
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			writeError(w, 400, err.Error())
+			return
+		}
+
+		writeJSON(w, 200, map[string]int{"received": received, "applied": applied})
+	case "DELETE":
+		if query := r.URL.Query(); !query.Has("from") || !query.Has("to") {
+			writeError(w, 400, "from and to are required to drop a range")
+			return
+		}
+
+		dropped, err := dataStore.Drop(keyRange)
+		if err != nil {
+			writeError(w, 500, err.Error())
+			return
+		}
+		writeJSON(w, 200, map[string]int{"dropped": dropped})
+	default:
+		methodNotAllowed(w, "GET", "POST", "DELETE")
+	}
+}
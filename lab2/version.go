@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Version orders the writes to a key across the replicas. Counter comes
+// from the clock of the store that took the write: the wall clock in
+// nanoseconds, bumped past every version the store has seen so it never
+// goes backwards. Node breaks ties between stores, so conflicting writes
+// resolve the same way on every replica: the newest one wins.
+type Version struct {
+	Counter uint64 `json:"counter"`
+	Node    string `json:"node,omitempty"`
+}
+
+// Newer reports whether v orders after other.
+func (v Version) Newer(other Version) bool {
+	if v.Counter != other.Counter {
+		return v.Counter > other.Counter
+	}
+	return v.Node > other.Node
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d@%s", v.Counter, v.Node)
+}
+
+// Entry is what a store holds for a key together with its version. A
+// deleted key keeps its entry as a tombstone, so that an older write
+// arriving late cannot bring it back.
+type Entry struct {
+	Value   string  `json:"value,omitempty"`
+	Version Version `json:"version"`
+	Deleted bool    `json:"deleted,omitempty"`
+}
+
+// SetNode names the store in the versions of its writes.
+func (d *DataStore) SetNode(node string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.node = node
+}
+
+// nextVersion returns a version newer than any the store has seen. The
+// caller must hold the write lock.
+func (d *DataStore) nextVersion() Version {
+	counter := max(uint64(time.Now().UnixNano()), d.clock+1)
+	d.clock = counter
+	return Version{Counter: counter, Node: d.node}
+}
+
+// observe moves the clock past a version written elsewhere. The caller
+// must hold the write lock.
+func (d *DataStore) observe(version Version) {
+	d.clock = max(d.clock, version.Counter)
+}
+
+// Lookup returns the entry of the key, including a tombstone for a key
+// that was deleted, and false if the key was never written.
+func (d *DataStore) Lookup(key string) (Entry, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	version, ok := d.versions[key]
+	if !ok {
+		return Entry{}, false
+	}
+
+	val, found := d.data[key]
+	return Entry{Value: val, Version: version, Deleted: !found}, true
+}
+
+// Merge applies an entry written on another replica if it is newer than
+// the one the store holds, and reports whether it was applied.
+func (d *DataStore) Merge(key string, entry Entry) (bool, error) {
+	if entry.Version == (Version{}) {
+		return false, errors.New("entry has no version")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if current, ok := d.versions[key]; ok && !entry.Version.Newer(current) {
+		return false, nil
+	}
+
+	r := record{Op: opSet, Key: key, Value: entry.Value, Version: entry.Version}
+	if entry.Deleted {
+		r = record{Op: opDelete, Key: key, Version: entry.Version}
+	}
+	if err := d.commit(r); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionNewer(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(Version{2, "a"}.Newer(Version{1, "b"}))
+	assert.False(Version{1, "b"}.Newer(Version{2, "a"}))
+
+	// equal counters are ordered by node, so every replica agrees
+	assert.True(Version{1, "b"}.Newer(Version{1, "a"}))
+	assert.False(Version{1, "a"}.Newer(Version{1, "b"}))
+	assert.False(Version{1, "a"}.Newer(Version{1, "a"}))
+
+	assert.Equal("5@http://localhost:3001", Version{5, "http://localhost:3001"}.String())
+}
+
+func TestDataStoreVersionsLocalWrites(t *testing.T) {
+	assert := assert.New(t)
+	store := NewDataStore()
+	store.SetNode("a")
+
+	_, ok := store.Lookup("1")
+	assert.False(ok)
+
+	assert.NoError(store.Set("1", "A"))
+	first, ok := store.Lookup("1")
+	assert.True(ok)
+	assert.Equal("A", first.Value)
+	assert.Equal("a", first.Version.Node)
+
+	// every write gets a newer version, even within a clock tick
+	assert.NoError(store.Set("1", "B"))
+	second, _ := store.Lookup("1")
+	assert.True(second.Version.Newer(first.Version))
+
+	// a delete leaves a newer tombstone
+	assert.NoError(store.UnSet("1"))
+	tombstone, ok := store.Lookup("1")
+	assert.True(ok)
+	assert.True(tombstone.Deleted)
+	assert.True(tombstone.Version.Newer(second.Version))
+}
+
+func TestDataStoreMerge(t *testing.T) {
+	assert := assert.New(t)
+	store := NewDataStore()
+	store.SetNode("a")
+
+	applied, err := store.Merge("1", Entry{Value: "A", Version: Version{10, "b"}})
+	assert.NoError(err)
+	assert.True(applied)
+
+	// older and equal versions lose
+	applied, err = store.Merge("1", Entry{Value: "old", Version: Version{9, "z"}})
+	assert.NoError(err)
+	assert.False(applied)
+	applied, _ = store.Merge("1", Entry{Value: "same", Version: Version{10, "b"}})
+	assert.False(applied)
+
+	// a tombstone keeps a late write from bringing the key back
+	applied, _ = store.Merge("1", Entry{Deleted: true, Version: Version{12, "c"}})
+	assert.True(applied)
+	applied, _ = store.Merge("1", Entry{Value: "late", Version: Version{11, "d"}})
+	assert.False(applied)
+	_, err = store.Get("1")
+	assert.ErrorIs(err, ErrKeyNotFound)
+
+	_, err = store.Merge("1", Entry{Value: "unversioned"})
+	assert.Error(err)
+
+	// local writes order after every version the store has seen
+	applied, _ = store.Merge("2", Entry{Value: "future", Version: Version{1 << 62, "z"}})
+	assert.True(applied)
+	assert.NoError(store.Set("2", "now"))
+	entry, _ := store.Lookup("2")
+	assert.Equal("now", entry.Value)
+	assert.Equal(uint64(1<<62+1), entry.Version.Counter)
+}
+
+func TestDataStoreRecoversVersions(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	store, err := OpenDataStore(dir, PersistOptions{SnapshotEvery: 2})
+	assert.NoError(err)
+	store.Merge("1", Entry{Value: "A", Version: Version{100, "b"}})
+	store.Merge("2", Entry{Deleted: true, Version: Version{200, "b"}})
+	store.Merge("3", Entry{Value: "C", Version: Version{300, "b"}})
+
+	// the first two entries are in the snapshot, the third in the log
+	recovered, err := OpenDataStore(dir, PersistOptions{})
+	assert.NoError(err)
+	defer recovered.Close()
+
+	for key, want := range map[string]Entry{
+		"1": {Value: "A", Version: Version{100, "b"}},
+		"2": {Deleted: true, Version: Version{200, "b"}},
+		"3": {Value: "C", Version: Version{300, "b"}},
+	} {
+		entry, ok := recovered.Lookup(key)
+		assert.True(ok)
+		assert.Equal(want, entry)
+	}
+}
+
+func TestDataStoreReadsSnapshotWithoutVersions(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	assert.NoError(os.WriteFile(filepath.Join(dir, snapshotFile), []byte(`{"1":"A","data":"B"}`), 0644))
+
+	store, err := OpenDataStore(dir, PersistOptions{})
+	assert.NoError(err)
+	defer store.Close()
+	assert.Equal(map[string]string{"1": "A", "data": "B"}, store.All())
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	walFile      = "wal.log"
+	snapshotFile = "snapshot.json"
+
+	// opSet and opDelete are the mutations a record can hold, and
+	// opDrop forgets a key that moved to another node, version and all
+	opSet    = "set"
+	opDelete = "del"
+	opDrop   = "drop"
+
+	defaultSnapshotEvery = 1000
+)
+
+// PersistOptions tunes how a DataStore is made durable.
+type PersistOptions struct {
+	// SnapshotEvery is the number of logged mutations after which the
+	// store is written to a snapshot and the log is truncated. Zero uses
+	// a default of 1000.
+	SnapshotEvery int
+	// Sync flushes every record to stable storage before the mutation is
+	// acknowledged, so data also survives a power failure and not just a
+	// killed process.
+	Sync bool
+}
+
+// record is a single mutation in the write-ahead log. Every mutation is
+// logged as its final value, so replaying a record twice is harmless.
+type record struct {
+	Op      string  `json:"op"`
+	Key     string  `json:"key"`
+	Value   string  `json:"value,omitempty"`
+	Version Version `json:"version"`
+}
+
+// apply performs the mutation of r on data and records its version.
+func (r record) apply(data map[string]string, versions map[string]Version) error {
+	switch r.Op {
+	case opSet:
+		data[r.Key] = r.Value
+	case opDelete:
+		delete(data, r.Key)
+	case opDrop:
+		delete(data, r.Key)
+		delete(versions, r.Key)
+		return nil
+	default:
+		return fmt.Errorf("unknown operation %q", r.Op)
+	}
+	versions[r.Key] = r.Version
+	return nil
+}
+
+// storeState is the content of a snapshot, which the log is replayed on.
+type storeState struct {
+	Data     map[string]string  `json:"data"`
+	Versions map[string]Version `json:"versions"`
+}
+
+// wal is the write-ahead log of a single node: one JSON record per line
+// in dir/wal.log, compacted from time to time into dir/snapshot.json.
+type wal struct {
+	dir           string
+	file          *os.File
+	records       int
+	snapshotEvery int
+	sync          bool
+}
+
+// openWAL loads the snapshot in dir, replays the log on top of it and
+// returns the recovered state together with the log, ready for appends.
+func openWAL(dir string, options PersistOptions) (*wal, *storeState, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, nil, err
+	}
+
+	state, err := readSnapshot(filepath.Join(dir, snapshotFile))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, walFile), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records, err := replay(file, state)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	// new records go after the last complete one
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	w := &wal{
+		dir:           dir,
+		file:          file,
+		records:       records,
+		snapshotEvery: options.SnapshotEvery,
+		sync:          options.Sync,
+	}
+	if w.snapshotEvery <= 0 {
+		w.snapshotEvery = defaultSnapshotEvery
+	}
+
+	return w, state, nil
+}
+
+// readSnapshot returns the state in a snapshot file, or an empty state if
+// no snapshot has been taken yet. Snapshots taken before keys had
+// versions hold nothing but the data.
+func readSnapshot(path string) (*storeState, error) {
+	state := &storeState{}
+
+	contents, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if len(contents) > 0 {
+		if err := json.Unmarshal(contents, state); err != nil || state.Data == nil {
+			state.Data, state.Versions = nil, nil
+			if err := json.Unmarshal(contents, &state.Data); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	}
+
+	if state.Data == nil {
+		state.Data = make(map[string]string)
+	}
+	if state.Versions == nil {
+		state.Versions = make(map[string]Version)
+	}
+	return state, nil
+}
+
+// replay applies every record of the log to state and returns how many
+// there were. A process killed in the middle of an append leaves a final
+// line without its newline; that torn record was never acknowledged, so
+// it is cut off instead of failing the recovery.
+func replay(file *os.File, state *storeState) (int, error) {
+	reader := bufio.NewReader(file)
+	offset := int64(0)
+	records := 0
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				// drop the torn record
+				return records, file.Truncate(offset)
+			}
+			return records, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		var r record
+		if err := json.Unmarshal(line, &r); err != nil {
+			return 0, fmt.Errorf("%s: record %d: %w", file.Name(), records+1, err)
+		}
+		if err := r.apply(state.Data, state.Versions); err != nil {
+			return 0, fmt.Errorf("%s: record %d: %w", file.Name(), records+1, err)
+		}
+
+		offset += int64(len(line))
+		records++
+	}
+}
+
+// append writes a record to the end of the log.
+func (w *wal) append(r record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if w.sync {
+		if err := w.file.Sync(); err != nil {
+			return err
+		}
+	}
+
+	w.records++
+	return nil
+}
+
+// full reports whether the log has grown enough to be compacted.
+func (w *wal) full() bool {
+	return w.records >= w.snapshotEvery
+}
+
+// snapshot writes the data and versions to the snapshot file and
+// truncates the log. The snapshot is written to a temporary file and
+// renamed into place, so a crash leaves either the old or the new
+// snapshot, and since records are idempotent a log that outlives its
+// snapshot replays to the same data.
+func (w *wal) snapshot(data map[string]string, versions map[string]Version) error {
+	contents, err := json.Marshal(storeState{Data: data, Versions: versions})
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(w.dir, snapshotFile)
+	tmp, err := os.CreateTemp(w.dir, snapshotFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// everything in the log is now part of the snapshot
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	w.records = 0
+	return nil
+}
+
+func (w *wal) close() error {
+	return w.file.Close()
+}
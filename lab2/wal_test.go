@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// logLines returns the complete records in the log of dir.
+func logLines(t *testing.T, dir string) []string {
+	contents, err := os.ReadFile(filepath.Join(dir, walFile))
+	assert.NoError(t, err)
+	return strings.Fields(string(contents))
+}
+
+func TestDataStoreRecoversFromLog(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	store, err := OpenDataStore(dir, PersistOptions{})
+	assert.NoError(err)
+
+	assert.NoError(store.Set("1", "A"))
+	assert.NoError(store.Set("2", "B"))
+	assert.NoError(store.Set("3", "C"))
+	assert.NoError(store.UnSet("2"))
+	swapped, err := store.CompareAndSwap("1", "A", "Z")
+	assert.NoError(err)
+	assert.True(swapped)
+	_, stored, err := store.PutIfAbsent("4", "D")
+	assert.NoError(err)
+	assert.True(stored)
+	_, err = store.Increment("count", 5)
+	assert.NoError(err)
+
+	// no Close: the store is dropped as if the process was killed
+	assert.Len(logLines(t, dir), 7)
+
+	recovered, err := OpenDataStore(dir, PersistOptions{})
+	assert.NoError(err)
+	defer recovered.Close()
+
+	assert.Equal(map[string]string{"1": "Z", "3": "C", "4": "D", "count": "5"}, recovered.All())
+}
+
+func TestDataStoreSkipsRepeatedDelete(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	store, err := OpenDataStore(dir, PersistOptions{})
+	assert.NoError(err)
+	defer store.Close()
+
+	// a key that was never written gets a tombstone, once
+	assert.NoError(store.UnSet("missing"))
+	assert.NoError(store.UnSet("missing"))
+	assert.Len(logLines(t, dir), 1)
+
+	entry, ok := store.Lookup("missing")
+	assert.True(ok)
+	assert.True(entry.Deleted)
+	assert.NotZero(entry.Version)
+}
+
+func TestDataStoreSnapshotCompactsLog(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	store, err := OpenDataStore(dir, PersistOptions{SnapshotEvery: 3, Sync: true})
+	assert.NoError(err)
+
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		assert.NoError(store.Set(key, strings.ToUpper(key)))
+	}
+
+	// two snapshots were taken, the last record is still only in the log
+	_, err = os.Stat(filepath.Join(dir, snapshotFile))
+	assert.NoError(err)
+	lines := logLines(t, dir)
+	assert.Len(lines, 1)
+	var r record
+	assert.NoError(json.Unmarshal([]byte(lines[0]), &r))
+	assert.Equal("g", r.Key)
+	assert.Equal("G", r.Value)
+
+	recovered, err := OpenDataStore(dir, PersistOptions{SnapshotEvery: 3})
+	assert.NoError(err)
+	defer recovered.Close()
+
+	assert.Equal(store.All(), recovered.All())
+	assert.Len(recovered.All(), 7)
+}
+
+func TestDataStoreExplicitSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	store, err := OpenDataStore(dir, PersistOptions{})
+	assert.NoError(err)
+	assert.NoError(store.Set("1", "A"))
+	assert.NoError(store.Snapshot())
+	assert.Empty(logLines(t, dir))
+
+	// appends after a snapshot start a fresh log
+	assert.NoError(store.Set("2", "B"))
+	assert.NoError(store.Close())
+
+	recovered, err := OpenDataStore(dir, PersistOptions{})
+	assert.NoError(err)
+	defer recovered.Close()
+	assert.Equal(map[string]string{"1": "A", "2": "B"}, recovered.All())
+
+	// an in-memory store has nothing to snapshot
+	assert.NoError(NewDataStore().Snapshot())
+}
+
+func TestDataStoreDropsTornRecord(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	store, err := OpenDataStore(dir, PersistOptions{})
+	assert.NoError(err)
+	assert.NoError(store.Set("1", "A"))
+	assert.NoError(store.Close())
+
+	// a crash in the middle of an append leaves half a record behind
+	file, err := os.OpenFile(filepath.Join(dir, walFile), os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(err)
+	_, err = file.WriteString(`{"op":"set","key":"2","va`)
+	assert.NoError(err)
+	assert.NoError(file.Close())
+
+	recovered, err := OpenDataStore(dir, PersistOptions{})
+	assert.NoError(err)
+	assert.Equal(map[string]string{"1": "A"}, recovered.All())
+
+	// the torn record is cut off so new records follow the last good one
+	assert.NoError(recovered.Set("3", "C"))
+	assert.NoError(recovered.Close())
+	assert.Len(logLines(t, dir), 2)
+
+	again, err := OpenDataStore(dir, PersistOptions{})
+	assert.NoError(err)
+	defer again.Close()
+	assert.Equal(map[string]string{"1": "A", "3": "C"}, again.All())
+}
+
+func TestDataStoreRejectsCorruptLog(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	contents := `{"op":"set","key":"1","value":"A"}` + "\n" +
+		"not a record\n" +
+		`{"op":"set","key":"2","value":"B"}` + "\n"
+	assert.NoError(os.WriteFile(filepath.Join(dir, walFile), []byte(contents), 0644))
+
+	_, err := OpenDataStore(dir, PersistOptions{})
+	assert.ErrorContains(err, "record 2")
+
+	contents = `{"op":"rename","key":"1"}` + "\n"
+	assert.NoError(os.WriteFile(filepath.Join(dir, walFile), []byte(contents), 0644))
+
+	_, err = OpenDataStore(dir, PersistOptions{})
+	assert.ErrorContains(err, `unknown operation "rename"`)
+}
+
+func TestDataStoreReplaysStaleLogOverSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	store, err := OpenDataStore(dir, PersistOptions{})
+	assert.NoError(err)
+	assert.NoError(store.Set("1", "A"))
+	assert.NoError(store.Set("1", "B"))
+	assert.NoError(store.UnSet("1"))
+	assert.NoError(store.Set("2", "C"))
+	stale, err := os.ReadFile(filepath.Join(dir, walFile))
+	assert.NoError(err)
+	assert.NoError(store.Snapshot())
+	assert.NoError(store.Close())
+
+	// a crash after the snapshot is renamed but before the log is
+	// truncated replays records that the snapshot already holds
+	assert.NoError(os.WriteFile(filepath.Join(dir, walFile), stale, 0644))
+
+	recovered, err := OpenDataStore(dir, PersistOptions{})
+	assert.NoError(err)
+	defer recovered.Close()
+	assert.Equal(map[string]string{"2": "C"}, recovered.All())
+}
+
+func TestDataStoreDropSurvivesRestart(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	store, err := OpenDataStore(dir, PersistOptions{})
+	assert.NoError(err)
+
+	keys := testKeys(20)
+	for _, key := range keys {
+		assert.NoError(store.Set(key, key))
+	}
+	assert.NoError(store.UnSet("0"))
+
+	// drop half of the ring, tombstones included
+	keyRange := KeyRange{Start: 0, End: 1 << 31}
+	dropped, err := store.Drop(keyRange)
+	assert.NoError(err)
+	assert.NotZero(dropped)
+	assert.Empty(store.Entries(keyRange))
+
+	recovered, err := OpenDataStore(dir, PersistOptions{})
+	assert.NoError(err)
+	defer recovered.Close()
+
+	assert.Empty(recovered.Entries(keyRange))
+	assert.Len(recovered.Entries(KeyRange{}), len(keys)-dropped)
+}
@@ -0,0 +1,152 @@
+package partition
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Comparison is how well a strategy partitions a set of keys over a set
+// of nodes.
+type Comparison struct {
+	Strategy string
+	Nodes    int
+	Keys     int
+	// RelativeStdDev is the standard deviation of the number of keys per
+	// node as a fraction of the mean, and MaxLoad the largest number of
+	// keys on a node over the mean.
+	RelativeStdDev float64
+	MaxLoad        float64
+	// MovedOnAdd and MovedOnRemove are the fractions of the keys that
+	// change node when a node joins or the first node leaves. The least
+	// possible are 1/(Nodes+1) and 1/Nodes.
+	MovedOnAdd    float64
+	MovedOnRemove float64
+	// Lookup is the mean time of a Get.
+	Lookup time.Duration
+}
+
+// Compare places the keys on the nodes with the named strategy, then adds
+// the node named added and removes the first node, and measures the load
+// balance, the keys moved and the lookup time.
+func Compare(strategy string, nodes []string, added string, keys []string) (Comparison, error) {
+	p, err := New(strategy)
+	if err != nil {
+		return Comparison{}, err
+	}
+	for _, node := range nodes {
+		p.Add(node)
+	}
+
+	c := Comparison{Strategy: strategy, Nodes: len(nodes), Keys: len(keys)}
+
+	start := time.Now()
+	owners, err := assignAll(p, keys)
+	if err != nil {
+		return Comparison{}, err
+	}
+	if len(keys) > 0 {
+		c.Lookup = time.Since(start) / time.Duration(len(keys))
+	}
+
+	counts := make(map[string]int, len(nodes))
+	for _, owner := range owners {
+		counts[owner]++
+	}
+	mean := float64(len(keys)) / float64(max(1, len(nodes)))
+	sumSquares := 0.0
+	for _, node := range nodes {
+		deviation := float64(counts[node]) - mean
+		sumSquares += deviation * deviation
+		if mean > 0 {
+			c.MaxLoad = math.Max(c.MaxLoad, float64(counts[node])/mean)
+		}
+	}
+	if mean > 0 {
+		c.RelativeStdDev = math.Sqrt(sumSquares/float64(len(nodes))) / mean
+	}
+
+	p.Add(added)
+	afterAdd, err := assignAll(p, keys)
+	if err != nil {
+		return Comparison{}, err
+	}
+	c.MovedOnAdd = moved(keys, owners, afterAdd)
+
+	if len(nodes) > 1 {
+		p.Remove(added)
+		p.Remove(nodes[0])
+		afterRemove, err := assignAll(p, keys)
+		if err != nil {
+			return Comparison{}, err
+		}
+		c.MovedOnRemove = moved(keys, owners, afterRemove)
+	}
+
+	return c, nil
+}
+
+// assignAll returns the node of every key, in the order of the keys.
+func assignAll(p Partitioner, keys []string) ([]string, error) {
+	owners := make([]string, len(keys))
+	for i, key := range keys {
+		owner, err := p.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		owners[i] = owner
+	}
+	return owners, nil
+}
+
+// moved returns the fraction of keys whose node differs.
+func moved(keys []string, before []string, after []string) float64 {
+	if len(keys) == 0 {
+		return 0
+	}
+
+	n := 0
+	for i := range keys {
+		if before[i] != after[i] {
+			n++
+		}
+	}
+	return float64(n) / float64(len(keys))
+}
+
+// WriteTable writes the comparisons as a table with one row per strategy.
+func WriteTable(w io.Writer, comparisons []Comparison) error {
+	t := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(t, "strategy\tnodes\tkeys\tstd dev\tmax load\tmoved on add\tmoved on remove\tlookup")
+	for _, c := range comparisons {
+		fmt.Fprintf(t, "%s\t%d\t%d\t%.2f%%\t%.3f\t%.2f%%\t%.2f%%\t%s\n",
+			c.Strategy, c.Nodes, c.Keys, 100*c.RelativeStdDev, c.MaxLoad,
+			100*c.MovedOnAdd, 100*c.MovedOnRemove, c.Lookup)
+	}
+	return t.Flush()
+}
+
+// WriteCSV writes the comparisons as CSV with a header row. Fractions are
+// written as they are, not as percentages, and lookups in nanoseconds.
+func WriteCSV(w io.Writer, comparisons []Comparison) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"strategy", "nodes", "keys", "relative_std_dev", "max_load", "moved_on_add", "moved_on_remove", "lookup_ns"})
+	for _, c := range comparisons {
+		writer.Write([]string{
+			c.Strategy,
+			strconv.Itoa(c.Nodes),
+			strconv.Itoa(c.Keys),
+			strconv.FormatFloat(c.RelativeStdDev, 'f', 6, 64),
+			strconv.FormatFloat(c.MaxLoad, 'f', 6, 64),
+			strconv.FormatFloat(c.MovedOnAdd, 'f', 6, 64),
+			strconv.FormatFloat(c.MovedOnRemove, 'f', 6, 64),
+			strconv.FormatInt(c.Lookup.Nanoseconds(), 10),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,68 @@
+package partition
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	keys := testKeys(20000)
+
+	for _, strategy := range Strategies {
+		assert := assert.New(t)
+
+		c, err := Compare(strategy, testMembers(5), "http://localhost:3006", keys)
+		assert.NoError(err)
+		assert.Equal(strategy, c.Strategy)
+		assert.Equal(5, c.Nodes)
+		assert.Equal(len(keys), c.Keys)
+
+		assert.Less(c.RelativeStdDev, 0.15, strategy)
+		assert.GreaterOrEqual(c.MaxLoad, 1.0, strategy)
+		assert.Less(c.MaxLoad, 1.25, strategy)
+		assert.InDelta(1.0/6, c.MovedOnAdd, 0.06, strategy)
+		assert.Positive(c.Lookup, strategy)
+
+		if strategy == "jump" {
+			// every bucket after the first is renumbered, so only the
+			// keys that jump from the last bucket to its new number stay
+			assert.InDelta(19.0/20, c.MovedOnRemove, 0.06, strategy)
+		} else {
+			assert.InDelta(1.0/5, c.MovedOnRemove, 0.06, strategy)
+		}
+	}
+}
+
+func TestCompareErrors(t *testing.T) {
+	_, err := Compare("modulo", testMembers(5), "http://localhost:3006", testKeys(10))
+	assert.Error(t, err)
+
+	_, err = Compare("ring", nil, "http://localhost:3006", testKeys(10))
+	assert.ErrorIs(t, err, ErrNoNodes)
+}
+
+func TestWriteComparisons(t *testing.T) {
+	assert := assert.New(t)
+	comparisons := []Comparison{
+		{Strategy: "ring", Nodes: 5, Keys: 100, RelativeStdDev: 0.1, MaxLoad: 1.2, MovedOnAdd: 0.15, MovedOnRemove: 0.2, Lookup: 250},
+		{Strategy: "hrw", Nodes: 5, Keys: 100, RelativeStdDev: 0.05, MaxLoad: 1.1, MovedOnAdd: 0.17, MovedOnRemove: 0.19, Lookup: 900},
+	}
+
+	var table bytes.Buffer
+	assert.NoError(WriteTable(&table, comparisons))
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	assert.Len(lines, 3)
+	assert.Equal([]string{"ring", "5", "100", "10.00%", "1.200", "15.00%", "20.00%", "250ns"}, strings.Fields(lines[1]))
+
+	var out bytes.Buffer
+	assert.NoError(WriteCSV(&out, comparisons))
+	records, err := csv.NewReader(&out).ReadAll()
+	assert.NoError(err)
+	assert.Len(records, 3)
+	assert.Equal("lookup_ns", records[0][7])
+	assert.Equal([]string{"hrw", "5", "100", "0.050000", "1.100000", "0.170000", "0.190000", "900"}, records[2])
+}
@@ -0,0 +1,74 @@
+package partition
+
+import "sort"
+
+// Jump is jump consistent hashing (Lamping and Veach, 2014): the nodes
+// are numbered buckets and a key jumps through bucket numbers seeded by
+// its hash, which needs no memory beyond the list of nodes and spreads
+// the keys almost perfectly evenly.
+//
+// The buckets are the nodes in sorted order, so every process that knows
+// the same nodes places the keys the same way whatever order it added
+// them in. Jump hashing can only grow or shrink at the end of that order:
+// a node that sorts after all the others takes only its share of the
+// keys, but adding or removing any other node renumbers the buckets after
+// it and moves most of the keys.
+type Jump struct {
+	nodes []string
+}
+
+func NewJump() *Jump {
+	return &Jump{}
+}
+
+// jumpHash returns the bucket of a key among the given number of buckets.
+func jumpHash(key uint64, buckets int) int {
+	b, j := int64(-1), int64(0)
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+func (j *Jump) Add(node string) {
+	i := sort.SearchStrings(j.nodes, node)
+	if i < len(j.nodes) && j.nodes[i] == node {
+		return
+	}
+	j.nodes = append(j.nodes, "")
+	copy(j.nodes[i+1:], j.nodes[i:])
+	j.nodes[i] = node
+}
+
+func (j *Jump) Remove(node string) error {
+	i := sort.SearchStrings(j.nodes, node)
+	if i == len(j.nodes) || j.nodes[i] != node {
+		return ErrNodeNotFound
+	}
+	j.nodes = append(j.nodes[:i], j.nodes[i+1:]...)
+	return nil
+}
+
+func (j *Jump) Get(key string) (string, error) {
+	if len(j.nodes) == 0 {
+		return "", ErrNoNodes
+	}
+	return j.nodes[jumpHash(hash64(key), len(j.nodes))], nil
+}
+
+// GetN returns the bucket of the key followed by the next n-1 buckets.
+func (j *Jump) GetN(key string, n int) ([]string, error) {
+	if len(j.nodes) == 0 {
+		return nil, ErrNoNodes
+	}
+
+	n = min(n, len(j.nodes))
+	first := jumpHash(hash64(key), len(j.nodes))
+	nodes := make([]string, 0, max(0, n))
+	for i := 0; i < n; i++ {
+		nodes = append(nodes, j.nodes[(first+i)%len(j.nodes)])
+	}
+	return nodes, nil
+}
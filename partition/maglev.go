@@ -0,0 +1,141 @@
+package partition
+
+import "sort"
+
+// DefaultMaglevTableSize is the size of the lookup table of a Maglev made
+// by NewMaglev. It is prime, and about a hundred times the number of
+// nodes it is meant for, which keeps every node within 1% of its share.
+const DefaultMaglevTableSize = 65537
+
+// Maglev is the consistent hashing of Google's Maglev load balancer
+// (Eisenbud et al., 2016). Every node fills the slots of a lookup table
+// in its own pseudo random order, taking turns with the others, so each
+// node owns almost exactly the same number of slots. A key belongs to
+// the node in the slot of its hash, which makes a lookup a single array
+// index. Adding or removing a node rebuilds the table and moves a few
+// keys between the remaining nodes as well.
+type Maglev struct {
+	size  uint64
+	nodes []string
+	// table holds the index in nodes of the owner of every slot
+	table []int
+}
+
+func NewMaglev() *Maglev {
+	return NewMaglevWithTableSize(DefaultMaglevTableSize)
+}
+
+// NewMaglevWithTableSize returns a Maglev with a lookup table of at least
+// size slots. The size is rounded up to a prime, which the permutations
+// of the nodes need to reach every slot.
+func NewMaglevWithTableSize(size int) *Maglev {
+	return &Maglev{size: nextPrime(uint64(max(2, size)))}
+}
+
+// nextPrime returns the smallest prime that is not less than n.
+func nextPrime(n uint64) uint64 {
+	for ; ; n++ {
+		prime := n >= 2
+		for d := uint64(2); d*d <= n && prime; d++ {
+			prime = n%d != 0
+		}
+		if prime {
+			return n
+		}
+	}
+}
+
+func (m *Maglev) Add(node string) {
+	i := sort.SearchStrings(m.nodes, node)
+	if i < len(m.nodes) && m.nodes[i] == node {
+		return
+	}
+
+	// the nodes are kept sorted so that the table does not depend on
+	// the order they were added in
+	m.nodes = append(m.nodes, "")
+	copy(m.nodes[i+1:], m.nodes[i:])
+	m.nodes[i] = node
+	m.populate()
+}
+
+func (m *Maglev) Remove(node string) error {
+	i := sort.SearchStrings(m.nodes, node)
+	if i >= len(m.nodes) || m.nodes[i] != node {
+		return ErrNodeNotFound
+	}
+
+	m.nodes = append(m.nodes[:i], m.nodes[i+1:]...)
+	m.populate()
+	return nil
+}
+
+// populate rebuilds the lookup table. Every node has a permutation of
+// the slots given by an offset and a skip derived from its name, and the
+// nodes take turns claiming the next free slot of their permutation.
+func (m *Maglev) populate() {
+	if len(m.nodes) == 0 {
+		m.table = nil
+		return
+	}
+
+	offsets := make([]uint64, len(m.nodes))
+	skips := make([]uint64, len(m.nodes))
+	next := make([]uint64, len(m.nodes))
+	for i, node := range m.nodes {
+		h := hash64(node)
+		offsets[i] = h % m.size
+		skips[i] = mix64(h)%(m.size-1) + 1
+	}
+
+	table := make([]int, m.size)
+	for i := range table {
+		table[i] = -1
+	}
+
+	for filled := uint64(0); ; {
+		for i := range m.nodes {
+			slot := (offsets[i] + next[i]*skips[i]) % m.size
+			for table[slot] >= 0 {
+				next[i]++
+				slot = (offsets[i] + next[i]*skips[i]) % m.size
+			}
+			table[slot] = i
+			next[i]++
+
+			filled++
+			if filled == m.size {
+				m.table = table
+				return
+			}
+		}
+	}
+}
+
+func (m *Maglev) Get(key string) (string, error) {
+	if len(m.nodes) == 0 {
+		return "", ErrNoNodes
+	}
+	return m.nodes[m.table[hash64(key)%m.size]], nil
+}
+
+// GetN returns the owner of the key's slot followed by the owners of the
+// next slots that belong to other nodes.
+func (m *Maglev) GetN(key string, n int) ([]string, error) {
+	if len(m.nodes) == 0 {
+		return nil, ErrNoNodes
+	}
+
+	n = min(n, len(m.nodes))
+	nodes := make([]string, 0, max(0, n))
+	seen := make(map[int]bool, max(0, n))
+	start := hash64(key) % m.size
+	for i := uint64(0); len(nodes) < n && i < m.size; i++ {
+		owner := m.table[(start+i)%m.size]
+		if !seen[owner] {
+			seen[owner] = true
+			nodes = append(nodes, m.nodes[owner])
+		}
+	}
+	return nodes, nil
+}
@@ -0,0 +1,80 @@
+// Package partition decides which nodes of a cluster store a key. Every
+// strategy implements Partitioner, so a client or server can pick one by
+// name with New:
+//
+//	ring    consistent hashing with virtual nodes (as the lab2 servers)
+//	hrw     rendezvous or highest random weight hashing (as lab3)
+//	jump    jump consistent hashing
+//	maglev  the lookup table of Google's Maglev load balancer
+package partition
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+var (
+	// ErrNoNodes is returned when a key is looked up before any node
+	// was added.
+	ErrNoNodes = errors.New("no nodes to partition over")
+	// ErrNodeNotFound is returned when removing a node that was never
+	// added.
+	ErrNodeNotFound = errors.New("node not found")
+)
+
+// Partitioner maps keys to the nodes that store them. Nodes are named by
+// any string, usually their base url.
+type Partitioner interface {
+	// Add places a node. Adding a node that is already there has no
+	// effect.
+	Add(node string)
+	// Remove takes a node out, so its keys go to the other nodes.
+	Remove(node string) error
+	// Get returns the node that stores the key.
+	Get(key string) (string, error)
+	// GetN returns the n distinct nodes that store the key, in order of
+	// preference, so the first one is the node Get returns. It returns
+	// every node when there are fewer than n.
+	GetN(key string, n int) ([]string, error)
+}
+
+// Strategies are the names New accepts.
+var Strategies = []string{"ring", "hrw", "jump", "maglev"}
+
+// New returns an empty partitioner of the named strategy, with its
+// default settings.
+func New(strategy string) (Partitioner, error) {
+	switch strategy {
+	case "ring":
+		return NewRing(), nil
+	case "hrw":
+		return NewRendezvous(), nil
+	case "jump":
+		return NewJump(), nil
+	case "maglev":
+		return NewMaglev(), nil
+	default:
+		return nil, fmt.Errorf("unknown partitioner %q, want one of %s", strategy, strings.Join(Strategies, ", "))
+	}
+}
+
+// hash64 returns a 64 bit hash of s. fnv-1a spreads short, similar keys
+// poorly in its high bits, so it is mixed with the murmur3 finaliser.
+func hash64(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return mix64(h.Sum64())
+}
+
+// mix64 is the 64 bit finaliser of murmur3: every bit of the input
+// affects every bit of the output.
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb3f99fdb8c5b
+	h ^= h >> 33
+	return h
+}
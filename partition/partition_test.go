@@ -0,0 +1,315 @@
+package partition
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testKeys returns n keys to partition.
+func testKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprint(i)
+	}
+	return keys
+}
+
+// newTestPartitioner returns a partitioner of the strategy with nodes
+// named after the ports 3001 and up.
+func newTestPartitioner(t *testing.T, strategy string, nodes int) Partitioner {
+	p, err := New(strategy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < nodes; i++ {
+		p.Add(fmt.Sprintf("http://localhost:%d", 3001+i))
+	}
+	return p
+}
+
+func TestNewRejectsUnknownStrategy(t *testing.T) {
+	_, err := New("modulo")
+	assert.EqualError(t, err, `unknown partitioner "modulo", want one of ring, hrw, jump, maglev`)
+}
+
+func TestPartitionerEmpty(t *testing.T) {
+	for _, strategy := range Strategies {
+		assert := assert.New(t)
+		p := newTestPartitioner(t, strategy, 0)
+
+		_, err := p.Get("1")
+		assert.ErrorIs(err, ErrNoNodes, strategy)
+		_, err = p.GetN("1", 3)
+		assert.ErrorIs(err, ErrNoNodes, strategy)
+		assert.ErrorIs(p.Remove("http://localhost:3001"), ErrNodeNotFound, strategy)
+
+		// the last node leaving empties it again
+		p.Add("http://localhost:3001")
+		assert.NoError(p.Remove("http://localhost:3001"), strategy)
+		_, err = p.Get("1")
+		assert.ErrorIs(err, ErrNoNodes, strategy)
+	}
+}
+
+func TestPartitionerGetN(t *testing.T) {
+	for _, strategy := range Strategies {
+		assert := assert.New(t)
+		p := newTestPartitioner(t, strategy, 5)
+
+		for _, key := range testKeys(1000) {
+			node, err := p.Get(key)
+			assert.NoError(err)
+
+			nodes, err := p.GetN(key, 3)
+			assert.NoError(err)
+			assert.Len(nodes, 3)
+			assert.Equal(node, nodes[0], strategy)
+			assert.NotEqual(nodes[0], nodes[1], strategy)
+			assert.NotEqual(nodes[1], nodes[2], strategy)
+			assert.NotEqual(nodes[0], nodes[2], strategy)
+		}
+
+		// asking for more nodes than there are returns all of them
+		nodes, err := p.GetN("1", 10)
+		assert.NoError(err)
+		assert.ElementsMatch(testMembers(5), nodes, strategy)
+
+		nodes, err = p.GetN("1", 0)
+		assert.NoError(err)
+		assert.Empty(nodes, strategy)
+	}
+}
+
+// testMembers returns the names newTestPartitioner gives its nodes.
+func testMembers(nodes int) []string {
+	members := make([]string, nodes)
+	for i := range members {
+		members[i] = fmt.Sprintf("http://localhost:%d", 3001+i)
+	}
+	return members
+}
+
+func TestPartitionerAddIsIdempotent(t *testing.T) {
+	for _, strategy := range Strategies {
+		p := newTestPartitioner(t, strategy, 5)
+		before := assign(t, p, testKeys(1000))
+
+		p.Add("http://localhost:3003")
+		assert.Equal(t, before, assign(t, p, testKeys(1000)), strategy)
+	}
+}
+
+func TestRingPoints(t *testing.T) {
+	assert := assert.New(t)
+
+	ring := NewRingWithVirtualNodes(1)
+	assert.Empty(ring.Points())
+	assert.Nil(ring.GetNAt(0, 1))
+
+	// with a single point per node, every node sits at its own hash
+	nodes := []string{"http://localhost:3001", "http://localhost:3002", "http://localhost:3003"}
+	hashes := make([]uint32, 0)
+	for _, node := range nodes {
+		ring.Add(node)
+		hashes = append(hashes, RingHash(node))
+		assert.Equal([]string{node}, ring.GetNAt(RingHash(node), 1))
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	assert.Equal(hashes, ring.Points())
+
+	// a key belongs to the nodes at the position of its hash
+	ring = NewRing()
+	for _, node := range nodes {
+		ring.Add(node)
+	}
+	assert.Len(ring.Points(), 3*DefaultVirtualNodes)
+	for _, key := range testKeys(1000) {
+		owners, err := ring.GetN(key, 2)
+		assert.NoError(err)
+		assert.Equal(owners, ring.GetNAt(RingHash(key), 2))
+	}
+}
+
+func TestAddKeepsWeight(t *testing.T) {
+	assert := assert.New(t)
+
+	ring := NewRing()
+	ring.AddWeighted("http://localhost:3001", 3)
+	ring.Add("http://localhost:3002")
+	before := assign(t, ring, testKeys(1000))
+
+	ring.Add("http://localhost:3001")
+	assert.Equal(3, ring.Weight("http://localhost:3001"))
+	assert.Equal(before, assign(t, ring, testKeys(1000)))
+
+	hrw := NewRendezvous()
+	hrw.AddWeighted("http://localhost:3001", 3)
+	hrw.Add("http://localhost:3001")
+	assert.Equal(3, hrw.Weight("http://localhost:3001"))
+}
+
+// assign returns the node every key belongs to.
+func assign(t *testing.T, p Partitioner, keys []string) map[string]string {
+	nodes := make(map[string]string, len(keys))
+	for _, key := range keys {
+		node, err := p.Get(key)
+		assert.NoError(t, err)
+		nodes[key] = node
+	}
+	return nodes
+}
+
+func TestPartitionerMovesFewKeys(t *testing.T) {
+	keys := testKeys(20000)
+
+	for _, strategy := range Strategies {
+		assert := assert.New(t)
+		p := newTestPartitioner(t, strategy, 5)
+		before := assign(t, p, keys)
+
+		// a new node takes about its share of the keys
+		added := "http://localhost:3006"
+		p.Add(added)
+		after := assign(t, p, keys)
+
+		moved := 0
+		for _, key := range keys {
+			if before[key] != after[key] {
+				moved++
+				if strategy != "maglev" {
+					// maglev also shuffles a few keys between the others
+					assert.Equal(added, after[key], strategy)
+				}
+			}
+		}
+		assert.InDelta(1.0/6, float64(moved)/float64(len(keys)), 0.06, strategy)
+
+		// and gives them back when it leaves
+		assert.NoError(p.Remove(added))
+		assert.Equal(before, assign(t, p, keys), strategy)
+	}
+}
+
+func TestPartitionerRemoveMovesOnlyItsKeys(t *testing.T) {
+	keys := testKeys(20000)
+
+	// jump renumbers the buckets after the removed node, so only
+	// strategies without an order keep every other key in place
+	for _, strategy := range []string{"ring", "hrw"} {
+		p := newTestPartitioner(t, strategy, 5)
+		before := assign(t, p, keys)
+
+		removed := "http://localhost:3002"
+		assert.NoError(t, p.Remove(removed))
+		after := assign(t, p, keys)
+
+		for _, key := range keys {
+			if before[key] != removed {
+				assert.Equal(t, before[key], after[key], strategy)
+			}
+		}
+	}
+}
+
+func TestPartitionerBalance(t *testing.T) {
+	keys := testKeys(100000)
+
+	for _, strategy := range Strategies {
+		p := newTestPartitioner(t, strategy, 5)
+
+		counts := make(map[string]int)
+		for _, node := range assign(t, p, keys) {
+			counts[node]++
+		}
+
+		assert.Len(t, counts, 5, strategy)
+		for node, count := range counts {
+			assert.InDelta(t, len(keys)/5, count, 0.2*float64(len(keys)/5), "%s: %s", strategy, node)
+		}
+	}
+}
+
+func TestRingAgreesWithWeights(t *testing.T) {
+	assert := assert.New(t)
+
+	ring := NewRing()
+	ring.AddWeighted("http://localhost:3001", 3)
+	ring.Add("http://localhost:3002")
+	assert.Equal([]string{"http://localhost:3001", "http://localhost:3002"}, ring.Members())
+
+	counts := make(map[string]int)
+	for _, node := range assign(t, ring, testKeys(40000)) {
+		counts[node]++
+	}
+	assert.InDelta(3.0, float64(counts["http://localhost:3001"])/float64(counts["http://localhost:3002"]), 0.6)
+}
+
+func TestJumpHash(t *testing.T) {
+	assert := assert.New(t)
+
+	// a key stays in its bucket or moves to the new one as buckets grow
+	for key := uint64(0); key < 1000; key++ {
+		previous := jumpHash(key, 1)
+		assert.Zero(previous)
+		for buckets := 2; buckets <= 20; buckets++ {
+			bucket := jumpHash(key, buckets)
+			assert.True(bucket == previous || bucket == buckets-1)
+			previous = bucket
+		}
+	}
+}
+
+func TestJumpIgnoresAddOrder(t *testing.T) {
+	assert := assert.New(t)
+	nodes := testMembers(5)
+
+	forward := NewJump()
+	for _, node := range nodes {
+		forward.Add(node)
+	}
+	backward := NewJump()
+	for i := len(nodes) - 1; i >= 0; i-- {
+		backward.Add(nodes[i])
+	}
+	backward.Add(nodes[2])
+	assert.Equal(nodes, backward.nodes)
+	assert.Equal(assign(t, forward, testKeys(1000)), assign(t, backward, testKeys(1000)))
+
+	// a node removed and added again gets its bucket back
+	before := assign(t, forward, testKeys(1000))
+	assert.NoError(forward.Remove(nodes[1]))
+	assert.ErrorIs(forward.Remove(nodes[1]), ErrNodeNotFound)
+	forward.Add(nodes[1])
+	assert.Equal(before, assign(t, forward, testKeys(1000)))
+}
+
+func TestMaglevTable(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(uint64(13), NewMaglevWithTableSize(12).size)
+	assert.Equal(uint64(65537), NewMaglev().size)
+
+	// every node owns the same number of slots, give or take one
+	m := NewMaglevWithTableSize(101)
+	for _, node := range testMembers(3) {
+		m.Add(node)
+	}
+	slots := make(map[int]int)
+	for _, owner := range m.table {
+		slots[owner]++
+	}
+	assert.Len(slots, 3)
+	for _, count := range slots {
+		assert.InDelta(101/3, count, 1)
+	}
+
+	// the table does not depend on the order the nodes were added in
+	other := NewMaglevWithTableSize(101)
+	for _, node := range []string{"http://localhost:3003", "http://localhost:3001", "http://localhost:3002"} {
+		other.Add(node)
+	}
+	assert.Equal(m.table, other.table)
+}
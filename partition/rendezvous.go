@@ -0,0 +1,131 @@
+package partition
+
+import (
+	"math"
+	"sort"
+)
+
+// rendezvousNode is a node with the hash its scores are computed from.
+type rendezvousNode struct {
+	name   string
+	hash   uint64
+	weight int
+}
+
+// Rendezvous is highest random weight hashing (Thaler and Ravishankar,
+// 1996): every node scores the key and the key belongs to the node with
+// the highest score, so removing a node only moves its own keys. This is
+// the partitioner of the lab3 client.
+//
+// A node of weight w scores -w / ln(u), where u is a uniform hash of the
+// node and the key between 0 and 1. This is weighted rendezvous hashing
+// (Schindelhauer and Schomaker, 2005): a node gets w times the keys of a
+// node of weight 1, and changing one weight only moves keys to or from
+// that node.
+type Rendezvous struct {
+	nodes []rendezvousNode
+}
+
+func NewRendezvous() *Rendezvous {
+	return &Rendezvous{}
+}
+
+// Add places a node of weight 1.
+func (h *Rendezvous) Add(node string) {
+	if h.index(node) >= 0 {
+		return
+	}
+	h.AddWeighted(node, 1)
+}
+
+// AddWeighted places a node with a capacity of weight, replacing its
+// previous weight if it is already there.
+func (h *Rendezvous) AddWeighted(node string, weight int) {
+	n := rendezvousNode{name: node, hash: hash64(node), weight: max(1, weight)}
+	if i := h.index(node); i >= 0 {
+		h.nodes[i] = n
+		return
+	}
+	h.nodes = append(h.nodes, n)
+}
+
+func (h *Rendezvous) Remove(node string) error {
+	i := h.index(node)
+	if i < 0 {
+		return ErrNodeNotFound
+	}
+
+	h.nodes = append(h.nodes[:i], h.nodes[i+1:]...)
+	return nil
+}
+
+// Weight returns the weight of a node, or 0 if it was not added.
+func (h *Rendezvous) Weight(node string) int {
+	if i := h.index(node); i >= 0 {
+		return h.nodes[i].weight
+	}
+	return 0
+}
+
+func (h *Rendezvous) index(node string) int {
+	for i, n := range h.nodes {
+		if n.name == node {
+			return i
+		}
+	}
+	return -1
+}
+
+// score is the score of the node for the key with the given hash.
+func (n rendezvousNode) score(keyHash uint64) float64 {
+	// the top 53 bits of the combined hash, as a float strictly
+	// between 0 and 1 so that the logarithm is finite and negative
+	u := (float64(mix64(n.hash^keyHash)>>11) + 0.5) / (1 << 53)
+	return -float64(n.weight) / math.Log(u)
+}
+
+// higher reports whether a node with score a ranks before one with score
+// b. Equal scores are broken by name, so the winner does not depend on
+// the order the nodes were added in.
+func higher(a float64, aName string, b float64, bName string) bool {
+	if a != b {
+		return a > b
+	}
+	return aName < bName
+}
+
+func (h *Rendezvous) Get(key string) (string, error) {
+	if len(h.nodes) == 0 {
+		return "", ErrNoNodes
+	}
+
+	keyHash := hash64(key)
+	best, bestScore := h.nodes[0].name, h.nodes[0].score(keyHash)
+	for _, node := range h.nodes[1:] {
+		if score := node.score(keyHash); higher(score, node.name, bestScore, best) {
+			best, bestScore = node.name, score
+		}
+	}
+
+	return best, nil
+}
+
+// GetN returns the n nodes with the highest scores for the key.
+func (h *Rendezvous) GetN(key string, n int) ([]string, error) {
+	if len(h.nodes) == 0 {
+		return nil, ErrNoNodes
+	}
+
+	keyHash := hash64(key)
+	scores := make(map[string]float64, len(h.nodes))
+	nodes := make([]string, 0, len(h.nodes))
+	for _, node := range h.nodes {
+		scores[node.name] = node.score(keyHash)
+		nodes = append(nodes, node.name)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return higher(scores[nodes[i]], nodes[i], scores[nodes[j]], nodes[j])
+	})
+	return nodes[:max(0, min(n, len(nodes)))], nil
+}
@@ -0,0 +1,95 @@
+package partition
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRendezvousIgnoresInsertionOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	members := testMembers(5)
+	forward, backward := NewRendezvous(), NewRendezvous()
+	for i := range members {
+		forward.Add(members[i])
+		backward.Add(members[len(members)-1-i])
+	}
+
+	for _, key := range testKeys(1000) {
+		a, err := forward.GetN(key, 5)
+		assert.NoError(err)
+		b, err := backward.GetN(key, 5)
+		assert.NoError(err)
+		assert.Equal(a, b, key)
+	}
+}
+
+func TestRendezvousBreaksTiesByName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(higher(2, "b", 1, "a"))
+	assert.True(higher(1, "a", 1, "b"))
+	assert.False(higher(1, "b", 1, "a"))
+
+	// two nodes with the same hash always tie, and the smaller name wins
+	h := NewRendezvous()
+	h.nodes = []rendezvousNode{{name: "b", hash: 42, weight: 1}, {name: "a", hash: 42, weight: 1}}
+	for _, key := range testKeys(100) {
+		node, err := h.Get(key)
+		assert.NoError(err)
+		assert.Equal("a", node)
+
+		nodes, err := h.GetN(key, 2)
+		assert.NoError(err)
+		assert.Equal([]string{"a", "b"}, nodes)
+	}
+}
+
+func TestRendezvousScoreIsFinite(t *testing.T) {
+	assert := assert.New(t)
+
+	// the extremes of the combined hash must not reach 0 or 1
+	for _, keyHash := range []uint64{0, 1, math.MaxUint64, 1 << 63} {
+		for _, nodeHash := range []uint64{0, 1, math.MaxUint64} {
+			score := rendezvousNode{hash: nodeHash, weight: 1}.score(keyHash)
+			assert.False(math.IsInf(score, 0) || math.IsNaN(score))
+			assert.Positive(score)
+		}
+	}
+}
+
+func TestRendezvousWeights(t *testing.T) {
+	assert := assert.New(t)
+	keys := testKeys(60000)
+
+	h := NewRendezvous()
+	h.AddWeighted("http://localhost:3001", 3)
+	h.Add("http://localhost:3002")
+	h.AddWeighted("http://localhost:3003", 2)
+	assert.Equal(3, h.Weight("http://localhost:3001"))
+	assert.Equal(1, h.Weight("http://localhost:3002"))
+	assert.Zero(h.Weight("http://localhost:3004"))
+
+	// Add leaves the weight of a node that is already there alone
+	h.Add("http://localhost:3001")
+	assert.Equal(3, h.Weight("http://localhost:3001"))
+
+	counts := make(map[string]int)
+	before := assign(t, h, keys)
+	for _, node := range before {
+		counts[node]++
+	}
+	assert.InDelta(len(keys)/2, counts["http://localhost:3001"], 0.05*float64(len(keys)))
+	assert.InDelta(len(keys)/6, counts["http://localhost:3002"], 0.05*float64(len(keys)))
+	assert.InDelta(len(keys)/3, counts["http://localhost:3003"], 0.05*float64(len(keys)))
+
+	// raising a weight only moves keys to that node
+	h.AddWeighted("http://localhost:3002", 3)
+	for key, node := range assign(t, h, keys) {
+		if node != before[key] {
+			assert.Equal("http://localhost:3002", node)
+		}
+	}
+}
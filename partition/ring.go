@@ -0,0 +1,184 @@
+package partition
+
+import (
+	"fmt"
+	"hash/crc32"
+	"sort"
+)
+
+// DefaultVirtualNodes is the number of points a node of weight 1 gets on
+// a ring made by NewRing.
+const DefaultVirtualNodes = 128
+
+// point is a position on the ring owned by a node.
+type point struct {
+	node string
+	hash uint32
+}
+
+// Ring is a consistent hash ring. Every node is placed at VirtualNodes
+// points per unit of weight and a key belongs to the first point at or
+// after its hash, so adding or removing a node only moves the keys of
+// the arcs it gains or loses. Points and GetNAt expose the arcs, for
+// moving the keys of an arc between nodes.
+type Ring struct {
+	VirtualNodes int
+	points       []point
+	weights      map[string]int
+}
+
+func NewRing() *Ring {
+	return NewRingWithVirtualNodes(DefaultVirtualNodes)
+}
+
+// NewRingWithVirtualNodes returns a ring that gives a node of weight 1
+// that many points. With 1 every node has a single point.
+func NewRingWithVirtualNodes(virtualNodes int) *Ring {
+	return &Ring{
+		VirtualNodes: max(1, virtualNodes),
+		weights:      make(map[string]int),
+	}
+}
+
+// RingHash returns the position of a key on a Ring.
+func RingHash(key string) uint32 {
+	return crc32.ChecksumIEEE([]byte(key))
+}
+
+// virtualPoint returns the i-th point of a node. The first point is the
+// hash of the node itself; crc32 maps similar names to nearby values, so
+// the others are mixed with the 32 bit finaliser of murmur3.
+func virtualPoint(node string, i int) point {
+	if i == 0 {
+		return point{node: node, hash: RingHash(node)}
+	}
+
+	h := RingHash(fmt.Sprintf("%s#%d", node, i))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+
+	return point{node: node, hash: h}
+}
+
+// Add places a node of weight 1 on the ring. A node that is already on
+// the ring keeps its weight.
+func (r *Ring) Add(node string) {
+	if _, ok := r.weights[node]; ok {
+		return
+	}
+	r.AddWeighted(node, 1)
+}
+
+// Weight returns the weight of a node, or 0 if it is not on the ring.
+func (r *Ring) Weight(node string) int {
+	return r.weights[node]
+}
+
+// AddWeighted places a node on the ring with weight times VirtualNodes
+// points, replacing its previous points if it is already there.
+func (r *Ring) AddWeighted(node string, weight int) {
+	r.Remove(node)
+
+	weight = max(1, weight)
+	r.weights[node] = weight
+	for i := 0; i < weight*r.VirtualNodes; i++ {
+		r.points = append(r.points, virtualPoint(node, i))
+	}
+
+	sort.Slice(r.points, func(i, j int) bool {
+		if r.points[i].hash != r.points[j].hash {
+			return r.points[i].hash < r.points[j].hash
+		}
+		// the owner of colliding points must not depend on insertion order
+		return r.points[i].node < r.points[j].node
+	})
+}
+
+// Remove takes every point of the node off the ring.
+func (r *Ring) Remove(node string) error {
+	if _, ok := r.weights[node]; !ok {
+		return ErrNodeNotFound
+	}
+	delete(r.weights, node)
+
+	points := r.points[:0]
+	for _, p := range r.points {
+		if p.node != node {
+			points = append(points, p)
+		}
+	}
+	r.points = points
+
+	return nil
+}
+
+// Members returns the nodes on the ring, sorted by name.
+func (r *Ring) Members() []string {
+	members := make([]string, 0, len(r.weights))
+	for node := range r.weights {
+		members = append(members, node)
+	}
+	sort.Strings(members)
+	return members
+}
+
+func (r *Ring) Get(key string) (string, error) {
+	nodes, err := r.GetN(key, 1)
+	if err != nil {
+		return "", err
+	}
+	return nodes[0], nil
+}
+
+// GetN walks the ring clockwise from the key and returns the first n
+// distinct nodes it meets.
+func (r *Ring) GetN(key string, n int) ([]string, error) {
+	if len(r.points) == 0 {
+		return nil, ErrNoNodes
+	}
+	return r.GetNAt(RingHash(key), n), nil
+}
+
+// Points returns the distinct positions of the points on the ring, in
+// order. Between two consecutive positions, every key has the same
+// nodes.
+func (r *Ring) Points() []uint32 {
+	positions := make([]uint32, 0, len(r.points))
+	for i, p := range r.points {
+		if i == 0 || p.hash != r.points[i-1].hash {
+			positions = append(positions, p.hash)
+		}
+	}
+	return positions
+}
+
+// GetNAt returns the first n distinct nodes at or after a position of
+// the ring, which are the nodes of the keys that hash to it. It returns
+// nil for an empty ring.
+func (r *Ring) GetNAt(hash uint32, n int) []string {
+	if len(r.points) == 0 {
+		return nil
+	}
+
+	start := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= hash
+	})
+
+	n = min(n, len(r.weights))
+	nodes := make([]string, 0, max(0, n))
+	seen := make(map[string]bool, max(0, n))
+	for i := 0; len(nodes) < n; i++ {
+		// wrap around at the end of the ring and skip the other
+		// points of the nodes already taken
+		node := r.points[(start+i)%len(r.points)].node
+		if !seen[node] {
+			seen[node] = true
+			nodes = append(nodes, node)
+		}
+	}
+
+	return nodes
+}